@@ -0,0 +1,299 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// shots waiting for analysis, further shots are not analysed while the queue is full
+	DefaultAnalysisQueueLength = 16
+)
+
+// AnalysisConfig selects the analyses run on every saved shot.
+type AnalysisConfig struct {
+	Impact ImpactConfig `json:"impact"`
+	Pose   PoseConfig   `json:"pose"`
+	Club   ClubConfig   `json:"club"`
+	Ball   BallConfig   `json:"ball"`
+	Tempo  TempoConfig  `json:"tempo"`
+	Head   HeadConfig   `json:"head"`
+}
+
+// Analyzer analyses the clip a camera recorded for a saved shot.
+// Results are recorded in info, which is saved with the shot's metadata, or in files in the shot directory.
+type Analyzer interface {
+	Name() string
+	Analyze(shot *Shot, clip *Clip, info *ShotClip) error
+	Close() error
+}
+
+// Analysis runs the analyzers on saved shots one at a time in the background,
+// decoding the clips from disk so capture is never slowed down.
+type Analysis struct {
+	analyzers []Analyzer
+	club      *ClubAnalyzer
+	queue     chan func()
+	stop      chan struct{}
+	impacts   chan ImpactUpdate
+	// number of analyses completed, overlays reload their results when it changes
+	completed atomic.Uint64
+}
+
+// NewAnalysis creates the configured analyzers, an analyzer that fails to load is left out so capture still runs.
+func NewAnalysis(cfg AnalysisConfig) *Analysis {
+	a := &Analysis{
+		queue:   make(chan func(), DefaultAnalysisQueueLength),
+		stop:    make(chan struct{}),
+		impacts: make(chan ImpactUpdate, DefaultAnalysisQueueLength),
+	}
+	// first, so the others use the refined impact frame
+	if len(cfg.Impact.Regions) > 0 {
+		a.add(NewImpactAnalyzer(cfg.Impact), nil)
+	}
+	if cfg.Pose.Model != "" {
+		a.add(NewPoseAnalyzer(cfg.Pose))
+	}
+	if cfg.Club.Enabled {
+		a.club = NewClubAnalyzer(cfg.Club)
+		a.add(a.club, nil)
+	}
+	if cfg.Ball.Camera != "" {
+		a.add(NewBallAnalyzer(cfg.Ball), nil)
+	}
+	// after the club tracking, which gives the top of the backswing
+	if cfg.Tempo.Enabled {
+		a.add(NewTempoAnalyzer(cfg.Tempo), nil)
+	}
+	// after the tempo, which gives the address frame
+	if cfg.Head.Model != "" {
+		a.add(NewHeadAnalyzer(cfg.Head))
+	}
+	return a
+}
+
+func (a *Analysis) add(analyzer Analyzer, err error) {
+	if err != nil {
+		fmt.Printf("Error creating analysis, it is disabled: %v\n", err)
+		return
+	}
+	a.analyzers = append(a.analyzers, analyzer)
+}
+
+// Queue queues a saved shot for analysis without blocking.
+func (a *Analysis) Queue(shot *Shot) {
+	if len(a.analyzers) == 0 {
+		return
+	}
+	a.enqueue(shot, func() { a.analyze(shot) })
+}
+
+// SeedClub tracks the club head of a camera's clip again, from a point clicked on one of its frames.
+// It is queued once the shot's clips are written, a shot still being saved can't be loaded yet.
+func (a *Analysis) SeedClub(shot *Shot, camera string, frame int, seed image.Point) {
+	if a.club == nil {
+		fmt.Println("club tracking is disabled")
+		return
+	}
+	go func() {
+		shot.WaitSaved()
+		a.enqueue(shot, func() {
+			clip, err := LoadClip(shot, camera)
+			if err != nil {
+				fmt.Printf("error loading %s clip of shot %s for analysis: %v\n", camera, shot.ID, err)
+				return
+			}
+			defer clip.Release()
+			if err := a.club.Track(shot, clip, frame, seed, true); err != nil {
+				fmt.Printf("error tracking club head on %s clip of shot %s: %v\n", camera, shot.ID, err)
+			}
+		})
+	}()
+}
+
+func (a *Analysis) enqueue(shot *Shot, job func()) {
+	select {
+	case a.queue <- job:
+	default:
+		fmt.Printf("analysis queue full, shot %s is not analysed\n", shot.ID)
+	}
+}
+
+// ImpactUpdate is a clip's impact frame as refined by the analysis.
+type ImpactUpdate struct {
+	Shot   *Shot
+	Camera string
+	Frame  int
+}
+
+// Impacts receives the impact frames the analysis moved, so a replay already playing can be realigned.
+// The channel is closed once the analysis stops, updates nobody receives are dropped.
+func (a *Analysis) Impacts() <-chan ImpactUpdate {
+	return a.impacts
+}
+
+// Completed returns the number of shots analysed so far.
+func (a *Analysis) Completed() uint64 {
+	return a.completed.Load()
+}
+
+// Start analyses the queued shots until Stop.
+func (a *Analysis) Start() {
+	defer a.close()
+	for {
+		select {
+		case <-a.stop:
+			return
+		case job := <-a.queue:
+			job()
+			a.completed.Add(1)
+		}
+	}
+}
+
+// Stop stops the analysis once the running analysis is done, queued analyses are dropped.
+func (a *Analysis) Stop() {
+	close(a.stop)
+}
+
+func (a *Analysis) analyze(shot *Shot) {
+	for _, camera := range shot.Cameras() {
+		info, _ := shot.Clip(camera)
+		impact := info.ImpactFrame
+		clip, err := LoadClip(shot, camera)
+		if err != nil {
+			fmt.Printf("error loading %s clip of shot %s for analysis: %v\n", camera, shot.ID, err)
+			continue
+		}
+		for _, analyzer := range a.analyzers {
+			start := time.Now()
+			if err := analyzer.Analyze(shot, clip, &info); err != nil {
+				fmt.Printf("error running %s analysis on %s clip of shot %s: %v\n", analyzer.Name(), info.Camera, shot.ID, err)
+				continue
+			}
+			fmt.Printf("%s analysis of %s clip of shot %s took %s\n", analyzer.Name(), info.Camera, shot.ID, time.Since(start))
+		}
+		clip.Release()
+		shot.UpdateClip(info)
+		if info.ImpactFrame != impact {
+			select {
+			case a.impacts <- ImpactUpdate{Shot: shot, Camera: camera, Frame: info.ImpactFrame}:
+			default:
+			}
+		}
+	}
+
+	if err := shot.Save(); err != nil {
+		fmt.Printf("error saving analysis of shot %s: %v\n", shot.ID, err)
+	}
+}
+
+func (a *Analysis) close() {
+	close(a.impacts)
+	for _, analyzer := range a.analyzers {
+		if err := analyzer.Close(); err != nil {
+			fmt.Printf("error closing %s analysis: %v\n", analyzer.Name(), err)
+		}
+	}
+}
+
+// clipDataFile is the file with a camera's per frame results of an analysis, e.g. "front.pose.json".
+func clipDataFile(camera, kind string) string {
+	return camera + "." + kind + ".json"
+}
+
+func saveClipData(shot *Shot, camera, kind string, v any) error {
+	b, err := json.Marshal(v)
+	if err == nil {
+		err = writeFileAtomic(shot.Path(clipDataFile(camera, kind)), b)
+	}
+	if err != nil {
+		return fmt.Errorf("error saving %s results: %w", kind, err)
+	}
+	return nil
+}
+
+func loadClipData(shot *Shot, camera, kind string, v any) error {
+	b, err := os.ReadFile(shot.Path(clipDataFile(camera, kind)))
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("error parsing %s results: %w", kind, err)
+	}
+	return nil
+}
+
+// clipResults caches a camera's results of an analysis for an overlay, it is used on the main thread.
+// The results are reloaded when another shot is replayed or an analysis completes.
+type clipResults[T any] struct {
+	analysis *Analysis
+	kind     string
+	// load returns the results of the shot, nil if it has none
+	load func(shot *Shot) (*T, error)
+
+	shotID    string
+	completed uint64
+	data      *T
+}
+
+// newClipResults caches the per frame results an analysis saved in its own file.
+func newClipResults[T any](analysis *Analysis, camera, kind string) *clipResults[T] {
+	load := func(shot *Shot) (*T, error) {
+		data := new(T)
+		err := loadClipData(shot, camera, kind, data)
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		return data, nil
+	}
+	return &clipResults[T]{analysis: analysis, kind: kind, load: load}
+}
+
+// newClipInfoResults caches the camera's clip metadata, with the results analyses record in the shot's metadata.
+// The metadata is read from disk as the replayed shot may have been loaded before its analysis completed.
+func newClipInfoResults(analysis *Analysis, camera, kind string) *clipResults[ShotClip] {
+	load := func(shot *Shot) (*ShotClip, error) {
+		saved, err := LoadShot(shot.Dir())
+		if errors.Is(err, os.ErrNotExist) {
+			// not saved yet
+			saved = shot
+		} else if err != nil {
+			return nil, err
+		}
+		info, ok := saved.Clip(camera)
+		if !ok {
+			return nil, nil
+		}
+		return &info, nil
+	}
+	return &clipResults[ShotClip]{analysis: analysis, kind: kind, load: load}
+}
+
+// get returns the results for the shot, nil if it has none.
+func (r *clipResults[T]) get(shot *Shot) *T {
+	if shot == nil {
+		return nil
+	}
+	completed := r.analysis.Completed()
+	if shot.ID == r.shotID && completed == r.completed {
+		return r.data
+	}
+	r.shotID, r.completed, r.data = shot.ID, completed, nil
+
+	data, err := r.load(shot)
+	if err != nil {
+		fmt.Printf("error loading %s results of shot %s: %v\n", r.kind, shot.ID, err)
+		return nil
+	}
+	r.data = data
+	return data
+}
@@ -0,0 +1,298 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"os"
+	"slices"
+
+	"gocv.io/x/gocv"
+)
+
+const (
+	// annotations are saved into the shot directory, by camera
+	AnnotationsFile = "annotations.json"
+
+	// HighGUI mouse events
+	mouseMove          = 0
+	mouseLeftButtonDn  = 1
+	mouseRightButtonDn = 2
+	mouseLeftButtonUp  = 4
+)
+
+var annotationColor = color.RGBA{R: 255, G: 255, B: 0, A: 255}
+
+type AnnotationKind string
+
+const (
+	AnnotationLine      AnnotationKind = "line"
+	AnnotationAngle     AnnotationKind = "angle"
+	AnnotationCircle    AnnotationKind = "circle"
+	AnnotationRectangle AnnotationKind = "rectangle"
+)
+
+var annotationKinds = []AnnotationKind{AnnotationLine, AnnotationAngle, AnnotationCircle, AnnotationRectangle}
+
+// Annotation is a shape drawn over a camera's replay, in frame coordinates.
+// Lines, circles (center, edge) and rectangles (corners) have 2 points, angles have 3 with the vertex in the middle.
+type Annotation struct {
+	Kind   AnnotationKind `json:"kind"`
+	Points []image.Point  `json:"points"`
+}
+
+func (a Annotation) points() int {
+	if a.Kind == AnnotationAngle {
+		return 3
+	}
+	return 2
+}
+
+// Draw draws the annotation, with the degree readout for angles.
+func (a Annotation) Draw(img *gocv.Mat) {
+	p := a.Points
+	switch {
+	case len(p) < 2:
+	case a.Kind == AnnotationLine:
+		gocv.Line(img, p[0], p[1], annotationColor, 2)
+	case a.Kind == AnnotationAngle:
+		gocv.Line(img, p[0], p[1], annotationColor, 2)
+		if len(p) < 3 {
+			return
+		}
+		gocv.Line(img, p[1], p[2], annotationColor, 2)
+		text := fmt.Sprintf("%.1f deg", angleDegrees(p[0], p[1], p[2]))
+		gocv.PutText(img, text, p[1].Add(image.Pt(10, -10)), gocv.FontHersheySimplex, 0.7, annotationColor, 2)
+	case a.Kind == AnnotationCircle:
+		d := p[1].Sub(p[0])
+		gocv.Circle(img, p[0], int(math.Hypot(float64(d.X), float64(d.Y))), annotationColor, 2)
+	case a.Kind == AnnotationRectangle:
+		gocv.Rectangle(img, image.Rectangle{Min: p[0], Max: p[1]}.Canon(), annotationColor, 2)
+	}
+}
+
+// Add returns the annotation moved by d.
+func (a Annotation) Add(d image.Point) Annotation {
+	points := make([]image.Point, len(a.Points))
+	for i, p := range a.Points {
+		points[i] = p.Add(d)
+	}
+	return Annotation{Kind: a.Kind, Points: points}
+}
+
+// referenceOffset is where the reference swing starts in a side by side frame, zero if the frame isn't side by side.
+func referenceOffset(frame PlaybackFrame) image.Point {
+	if frame.Compare != CompareSideBySide {
+		return image.Point{}
+	}
+	return image.Pt(frame.Frame.Cols()/2, 0)
+}
+
+// angleDegrees returns the angle at vertex between the rays to a and b.
+func angleDegrees(a, vertex, b image.Point) float64 {
+	da, db := a.Sub(vertex), b.Sub(vertex)
+	angle := math.Atan2(float64(db.Y), float64(db.X)) - math.Atan2(float64(da.Y), float64(da.X))
+	degrees := math.Abs(angle * 180 / math.Pi)
+	if degrees > 180 {
+		degrees = 360 - degrees
+	}
+	return degrees
+}
+
+// Annotator lets coaches draw lines, angles, circles and rectangles on the replays with the mouse.
+// Annotations stay on screen for the whole clip and are saved with the shot.
+// All its methods run on the main thread, from the window's mouse handler, key handling and overlays.
+type Annotator struct {
+	cameras []string
+	windows []*VideoPlaybackWindow
+	tool    AnnotationKind
+
+	// annotations by camera of the shot being replayed
+	shot        *Shot
+	annotations map[string][]Annotation
+	// annotation being drawn and the camera it is drawn on
+	drawing *Annotation
+	camera  int
+	// frame shown by each camera, and the function picking a point with the next click instead of drawing
+	frames []PlaybackFrame
+	pick   func(camera int, frame PlaybackFrame, pt image.Point)
+}
+
+func NewAnnotator(cameras []string, windows []*VideoPlaybackWindow) *Annotator {
+	a := &Annotator{
+		cameras:     cameras,
+		windows:     windows,
+		tool:        AnnotationLine,
+		annotations: make(map[string][]Annotation),
+		frames:      make([]PlaybackFrame, len(windows)),
+	}
+	for i, w := range windows {
+		w.SetMouseHandler(a.onMouse, i)
+		w.AddOverlay(annotationOverlay{annotator: a, camera: i})
+	}
+	return a
+}
+
+// RegisterCommands selects the drawing tool and clears the annotations with the commands of keys pressed in any window.
+func (a *Annotator) RegisterCommands(d *CommandDispatcher) {
+	d.Handle("draw", func(arg string) {
+		tool := AnnotationKind(arg)
+		if !slices.Contains(annotationKinds, tool) {
+			fmt.Printf("unknown drawing tool %q\n", arg)
+			return
+		}
+		a.tool = tool
+		a.drawing = nil
+		fmt.Printf("drawing tool: %s\n", tool)
+	})
+	d.Handle("clear_annotations", func(string) {
+		a.annotations = make(map[string][]Annotation)
+		a.drawing = nil
+		a.save()
+		a.refresh()
+	})
+}
+
+// PickPoint calls fn with the point of the next left click in any window, and the frame it was clicked on.
+func (a *Annotator) PickPoint(fn func(camera int, frame PlaybackFrame, pt image.Point)) {
+	a.pick = fn
+	a.drawing = nil
+}
+
+func (a *Annotator) onMouse(event, x, y, flags int, userdata any) {
+	camera := userdata.(int)
+	pt := image.Pt(x, y)
+	// annotations are in the replay's coordinates, clicks on the reference swing beside it map onto the replay
+	if offset := referenceOffset(a.frames[camera]); offset.X > 0 && pt.X >= offset.X {
+		pt = pt.Sub(offset)
+	}
+
+	switch event {
+	case mouseLeftButtonDn:
+		if a.pick != nil {
+			pick := a.pick
+			a.pick = nil
+			pick(camera, a.frames[camera], pt)
+			return
+		}
+		if a.drawing != nil && a.camera == camera && a.drawing.Kind == AnnotationAngle && len(a.drawing.Points) == 3 {
+			// the click places the end of the angle's second ray
+			a.drawing.Points[2] = pt
+			a.finish()
+			break
+		}
+		a.drawing = &Annotation{Kind: a.tool, Points: []image.Point{pt, pt}}
+		a.camera = camera
+	case mouseMove:
+		if a.drawing == nil || a.camera != camera {
+			return
+		}
+		a.drawing.Points[len(a.drawing.Points)-1] = pt
+	case mouseLeftButtonUp:
+		if a.drawing == nil || a.camera != camera || len(a.drawing.Points) != 2 {
+			return
+		}
+		a.drawing.Points[1] = pt
+		if d := a.drawing.Points[1].Sub(a.drawing.Points[0]); d.X*d.X+d.Y*d.Y < 9 {
+			// a click without a drag
+			a.drawing = nil
+			break
+		}
+		if a.drawing.points() == 3 {
+			// the drag drew the first ray of the angle, the second follows the mouse until clicked
+			a.drawing.Points = append(a.drawing.Points, pt)
+			break
+		}
+		a.finish()
+	case mouseRightButtonDn:
+		// cancel the annotation being drawn, or remove the last one
+		if a.drawing != nil {
+			a.drawing = nil
+			break
+		}
+		name := a.cameras[camera]
+		if n := len(a.annotations[name]); n > 0 {
+			a.annotations[name] = a.annotations[name][:n-1]
+			a.save()
+		}
+	default:
+		return
+	}
+	a.windows[camera].Refresh()
+}
+
+func (a *Annotator) finish() {
+	name := a.cameras[a.camera]
+	a.annotations[name] = append(a.annotations[name], *a.drawing)
+	a.drawing = nil
+	a.save()
+}
+
+func (a *Annotator) refresh() {
+	for _, w := range a.windows {
+		w.Refresh()
+	}
+}
+
+// load restores the annotations saved with the shot when a different shot starts replaying.
+func (a *Annotator) load(shot *Shot) {
+	if shot == a.shot || (shot != nil && a.shot != nil && shot.ID == a.shot.ID) {
+		return
+	}
+	a.shot = shot
+	a.annotations = make(map[string][]Annotation)
+	a.drawing = nil
+	if shot == nil {
+		return
+	}
+	b, err := os.ReadFile(shot.Path(AnnotationsFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return
+	}
+	if err == nil {
+		err = json.Unmarshal(b, &a.annotations)
+	}
+	if err != nil {
+		fmt.Printf("error loading annotations of shot %s: %v\n", shot.ID, err)
+	}
+}
+
+func (a *Annotator) save() {
+	if a.shot == nil {
+		return
+	}
+	b, err := json.MarshalIndent(a.annotations, "", "  ")
+	if err == nil {
+		err = writeFileAtomic(a.shot.Path(AnnotationsFile), b)
+	}
+	if err != nil {
+		fmt.Printf("error saving annotations of shot %s: %v\n", a.shot.ID, err)
+	}
+}
+
+// annotationOverlay draws a camera's annotations.
+type annotationOverlay struct {
+	annotator *Annotator
+	camera    int
+}
+
+func (o annotationOverlay) Draw(img *gocv.Mat, frame PlaybackFrame) {
+	a := o.annotator
+	a.frames[o.camera] = frame
+	a.load(frame.Shot)
+	annotations := a.annotations[a.cameras[o.camera]]
+	if a.drawing != nil && a.camera == o.camera {
+		annotations = append(slices.Clip(annotations), *a.drawing)
+	}
+	// repeated over the reference swing, so both can be measured against the same lines
+	offset := referenceOffset(frame)
+	for _, annotation := range annotations {
+		annotation.Draw(img)
+		if offset.X > 0 {
+			annotation.Add(offset).Draw(img)
+		}
+	}
+}
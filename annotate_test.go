@@ -0,0 +1,31 @@
+package main
+
+import (
+	"image"
+	"math"
+	"testing"
+)
+
+func TestAngleDegrees(t *testing.T) {
+	tests := []struct {
+		name      string
+		a, vertex image.Point
+		b         image.Point
+		want      float64
+	}{
+		{name: "right angle", a: image.Pt(1, 0), vertex: image.Pt(0, 0), b: image.Pt(0, 1), want: 90},
+		{name: "straight", a: image.Pt(-1, 0), vertex: image.Pt(0, 0), b: image.Pt(1, 0), want: 180},
+		{name: "same ray", a: image.Pt(2, 0), vertex: image.Pt(0, 0), b: image.Pt(5, 0), want: 0},
+		{name: "acute", a: image.Pt(1, 0), vertex: image.Pt(0, 0), b: image.Pt(1, 1), want: 45},
+		{name: "either order", a: image.Pt(1, 1), vertex: image.Pt(0, 0), b: image.Pt(1, 0), want: 45},
+		{name: "rays either side of the negative x axis", a: image.Pt(-1, 1), vertex: image.Pt(0, 0), b: image.Pt(-1, -1), want: 90},
+		{name: "vertex away from the origin", a: image.Pt(10, 5), vertex: image.Pt(5, 5), b: image.Pt(5, 0), want: 90},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := angleDegrees(tt.a, tt.vertex, tt.b); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("angleDegrees(%v, %v, %v) = %f, want %f", tt.a, tt.vertex, tt.b, got, tt.want)
+			}
+		})
+	}
+}
@@ -40,6 +40,7 @@ var tmpl = template.Must(template.New("").Parse(
 type Audio struct {
 	detection        chan Detection
 	decibleThreshold float64
+	metrics          audioMetrics
 }
 
 func NewAudio(decibelThreshold float64) (*Audio, error) {
@@ -70,6 +71,8 @@ func (a *Audio) StartDetection(minDetectionInterval time.Duration) (err error) {
 	// Create a buffer to hold the recorded audio
 	buffer := make([]int32, maxSignalLength)
 	var bite []float64
+	// time the last sample in bite was read
+	var biteEnd time.Time
 	var mutex sync.RWMutex
 
 	// Open the audio stream
@@ -92,7 +95,9 @@ func (a *Audio) StartDetection(minDetectionInterval time.Duration) (err error) {
 			if len(bite) > maxSignalLength {
 				bite = bite[len(bite)-maxSignalLength:]
 			}
+			biteEnd = time.Now()
 			mutex.Unlock()
+			a.metrics.read(biteEnd)
 		}
 	}()
 
@@ -107,7 +112,7 @@ func (a *Audio) StartDetection(minDetectionInterval time.Duration) (err error) {
 
 	detectTicker := time.NewTicker(detectInterval).C
 	var lastDetection time.Time
-	for range detectTicker {
+	for tick := range detectTicker {
 		mutex.RLock()
 
 		decibels := calculateDecibels(bite)
@@ -117,13 +122,19 @@ func (a *Audio) StartDetection(minDetectionInterval time.Duration) (err error) {
 		}
 		if decibels > a.decibleThreshold && time.Now().Add(-minDetectionInterval).After(lastDetection) {
 			lastDetection = time.Now()
+			// the strike is the loudest sample in the bite, work back from the end of the bite to when it was heard
+			sinceImpact := time.Duration(len(bite)-1-peakIndex(bite)) * time.Second / sampleRate
+			a.metrics.detected()
 			a.detection <- Detection{
+				Source:        DetectionSourceAudio,
 				Decibel:       decibels,
 				DetectionTime: time.Now(),
+				ImpactTime:    biteEnd.Add(-sinceImpact),
 			}
 		}
 
 		mutex.RUnlock()
+		a.metrics.loopTick(tick, time.Since(tick))
 	}
 	return nil
 }
@@ -132,6 +143,11 @@ func (a *Audio) DetectAboveThreshold() <-chan Detection {
 	return a.detection
 }
 
+// Stats returns the timing metrics of the detection loop.
+func (a *Audio) Stats() AudioStats {
+	return a.metrics.snapshot()
+}
+
 // calculateDecibels converts RMS to decibels (dB)
 func calculateDecibels(signal []float64) float64 {
 	rms := calculateRMS(signal)
@@ -154,7 +170,28 @@ func calculateRMS(samples []float64) float64 {
 	return math.Sqrt(mean)
 }
 
+// peakIndex returns the index of the sample with the largest amplitude
+func peakIndex(samples []float64) int {
+	var peak int
+	for i, sample := range samples {
+		if math.Abs(sample) > math.Abs(samples[peak]) {
+			peak = i
+		}
+	}
+	return peak
+}
+
+const (
+	DetectionSourceAudio = "audio"
+	// saved with the save command
+	DetectionSourceManual = "manual"
+)
+
 type Detection struct {
+	// what triggered the detection, e.g. DetectionSourceAudio
+	Source        string
 	Decibel       float64
 	DetectionTime time.Time
+	// estimated time of the strike, slightly before DetectionTime
+	ImpactTime time.Time
 }
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+const (
+	// name of the ball launch analysis
+	BallAnalysis         = "ball"
+	DefaultBallMinRadius = 4
+	DefaultBallMaxRadius = 30
+	// frames after impact the ball is followed for, it is soon too small or out of the frame
+	DefaultBallTrackFrames = 8
+	// horizontal field of view in degrees assumed when the focal length isn't configured, typical of webcams
+	DefaultBallFieldOfView = 70
+	// the ball is found at address this long before impact, when the club is away from it
+	DefaultBallAddressOffset = 500 * time.Millisecond
+	// how far the ball is searched for from where it is expected, in ball radii per frame
+	DefaultBallSearchRadii = 10
+	// circle detector thresholds, the canny edge threshold and the accumulator threshold
+	ballEdgeThreshold   = 100
+	ballCircleThreshold = 20
+)
+
+var (
+	ballTargetColor = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	ballPathColor   = color.RGBA{R: 255, G: 140, B: 255, A: 255}
+)
+
+// BallConfig enables finding the ball on the down the line camera and estimating its launch direction.
+type BallConfig struct {
+	// camera behind the ball looking down the target line, empty disables ball detection
+	Camera string `json:"camera"`
+	// area the ball is searched for at address, empty searches the whole frame
+	ROI       Region `json:"roi"`
+	MinRadius int    `json:"min_radius"`
+	MaxRadius int    `json:"max_radius"`
+	// point on the horizon the target line runs to, e.g. the flag;
+	// without one the target line runs straight away from the camera
+	Target *FramePoint `json:"target"`
+	// focal length of the camera in pixels, 0 estimates it from DefaultBallFieldOfView
+	FocalLength float64 `json:"focal_length"`
+	TrackFrames int     `json:"track_frames"`
+}
+
+func DefaultBallConfig() BallConfig {
+	return BallConfig{
+		MinRadius:   DefaultBallMinRadius,
+		MaxRadius:   DefaultBallMaxRadius,
+		TrackFrames: DefaultBallTrackFrames,
+	}
+}
+
+// Region is an area of a frame in pixels.
+type Region struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+func (r Region) Rect() image.Rectangle {
+	return image.Rect(r.X, r.Y, r.X+r.Width, r.Y+r.Height)
+}
+
+// FramePoint is a point of a frame in pixels.
+type FramePoint struct {
+	X int `json:"x"`
+	Y int `json:"y"`
+}
+
+func (p FramePoint) Point() image.Point {
+	return image.Pt(p.X, p.Y)
+}
+
+// BallPoint is where the ball was found in a frame.
+type BallPoint struct {
+	Frame  int     `json:"frame"`
+	X      int     `json:"x"`
+	Y      int     `json:"y"`
+	Radius float64 `json:"radius"`
+}
+
+func (p BallPoint) Point() image.Point {
+	return image.Pt(p.X, p.Y)
+}
+
+// BallLaunch is the direction the ball started in, estimated from its position and size in the frames after impact.
+type BallLaunch struct {
+	// degrees right of the target line, negative is left
+	Horizontal float64 `json:"horizontal"`
+	// degrees above the ground, assuming the camera is level
+	Vertical float64 `json:"vertical"`
+	// the ball at address followed by the frames it was found in after impact
+	Path []BallPoint `json:"path"`
+	// point the target line runs to from the ball
+	Target FramePoint `json:"target"`
+}
+
+// BallAnalyzer finds the ball at address with circle detection and follows it over the first frames after impact.
+type BallAnalyzer struct {
+	cfg BallConfig
+}
+
+func NewBallAnalyzer(cfg BallConfig) *BallAnalyzer {
+	cfg.MinRadius = max(cfg.MinRadius, 1)
+	cfg.MaxRadius = max(cfg.MaxRadius, cfg.MinRadius)
+	cfg.TrackFrames = max(cfg.TrackFrames, 1)
+	return &BallAnalyzer{cfg: cfg}
+}
+
+func (b *BallAnalyzer) Name() string {
+	return BallAnalysis
+}
+
+// Analyze records the launch in info, only the configured camera's clip is analysed.
+func (b *BallAnalyzer) Analyze(shot *Shot, clip *Clip, info *ShotClip) error {
+	if clip.Camera != b.cfg.Camera {
+		return nil
+	}
+	impact := clip.ImpactFrame
+	if impact < 1 || impact >= clip.Len()-1 {
+		return fmt.Errorf("impact frame %d is at the edge of the clip", impact)
+	}
+	frames := newGrayFrames(clip)
+	defer frames.Close()
+
+	first := clip.Frame(0)
+	bounds := image.Rect(0, 0, first.Cols(), first.Rows())
+	roi := bounds
+	if b.cfg.ROI.Width > 0 && b.cfg.ROI.Height > 0 {
+		roi = b.cfg.ROI.Rect().Intersect(bounds)
+	}
+	address := max(0, impact-int(clip.FPS*DefaultBallAddressOffset.Seconds()))
+	ball, ok := findBall(frames.Frame(address), roi, b.cfg.MinRadius, b.cfg.MaxRadius, nil)
+	if !ok {
+		return fmt.Errorf("no ball found at address")
+	}
+	ball.Frame = address
+
+	// the ball gets smaller as it flies away from the camera
+	path := []BallPoint{ball}
+	last := ball
+	var velocity image.Point
+	for i := impact + 1; i < clip.Len() && i <= impact+b.cfg.TrackFrames; i++ {
+		gap := i - max(last.Frame, impact)
+		expected := last.Point().Add(velocity.Mul(gap))
+		reach := int(last.Radius*DefaultBallSearchRadii) * gap
+		area := image.Rect(expected.X-reach, expected.Y-reach, expected.X+reach, expected.Y+reach).Intersect(bounds)
+		found, ok := findBall(frames.Frame(i), area, 1, int(math.Ceil(last.Radius))+2, &expected)
+		// a circle where the ball was is the tee or the ball not hit yet
+		if !ok || dist(found.Point(), ball.Point()) < ball.Radius {
+			continue
+		}
+		found.Frame = i
+		if len(path) > 1 {
+			velocity = found.Point().Sub(last.Point()).Div(found.Frame - last.Frame)
+		}
+		path = append(path, found)
+		last = found
+	}
+	if len(path) < 2 {
+		return fmt.Errorf("ball not found after impact")
+	}
+
+	launch := b.launch(path, bounds.Size())
+	info.Launch = &launch
+	fmt.Printf(">>>>>>>> shot %s launched %.1f deg horizontal, %.1f deg vertical\n", shot.ID, launch.Horizontal, launch.Vertical)
+	return nil
+}
+
+// launch estimates the launch angles from the ball's positions in camera space.
+// The ball's distance from the camera follows from its radius, in units of the ball's radius which cancel out of the angles.
+func (b *BallAnalyzer) launch(path []BallPoint, size image.Point) BallLaunch {
+	f := b.cfg.FocalLength
+	if f <= 0 {
+		f = float64(size.X) / 2 / math.Tan(DefaultBallFieldOfView/2*math.Pi/180)
+	}
+	center := size.Div(2)
+	position := func(p BallPoint) (x, y, z float64) {
+		return float64(p.X-center.X) / p.Radius, float64(p.Y-center.Y) / p.Radius, f / p.Radius
+	}
+	x0, y0, z0 := position(path[0])
+	x1, y1, z1 := position(path[len(path)-1])
+	dx, dy, dz := x1-x0, y1-y0, z1-z0
+
+	// a point on the horizon is in the same direction from the ball as from the camera
+	target := FramePoint{X: center.X, Y: center.Y}
+	if b.cfg.Target != nil {
+		target = *b.cfg.Target
+	}
+	targetAngle := math.Atan2(float64(target.X-center.X), f)
+	return BallLaunch{
+		Horizontal: (math.Atan2(dx, dz) - targetAngle) * 180 / math.Pi,
+		// y points down in the frame
+		Vertical: math.Atan2(-dy, math.Hypot(dx, dz)) * 180 / math.Pi,
+		Path:     path,
+		Target:   target,
+	}
+}
+
+// findBall finds the brightest circle in an area of a gray frame, or the one closest to near.
+func findBall(gray gocv.Mat, area image.Rectangle, minRadius, maxRadius int, near *image.Point) (BallPoint, bool) {
+	if area.Empty() {
+		return BallPoint{}, false
+	}
+	roi := gray.Region(area)
+	defer roi.Close()
+	blurred := gocv.NewMat()
+	defer blurred.Close()
+	gocv.MedianBlur(roi, &blurred, 5)
+	circles := gocv.NewMat()
+	defer circles.Close()
+	gocv.HoughCirclesWithParams(blurred, &circles, gocv.HoughGradient, 1, float64(2*minRadius), ballEdgeThreshold, ballCircleThreshold, minRadius, maxRadius)
+
+	var best BallPoint
+	bestScore := math.Inf(-1)
+	for i := 0; i < circles.Cols(); i++ {
+		v := circles.GetVecfAt(0, i)
+		x, y := int(v[0]), int(v[1])
+		if x < 0 || y < 0 || x >= blurred.Cols() || y >= blurred.Rows() {
+			continue
+		}
+		p := BallPoint{X: x + area.Min.X, Y: y + area.Min.Y, Radius: float64(v[2])}
+		score := float64(blurred.GetUCharAt(y, x))
+		if near != nil {
+			score = -dist(p.Point(), *near)
+		}
+		if score > bestScore {
+			best, bestScore = p, score
+		}
+	}
+	return best, !math.IsInf(bestScore, -1)
+}
+
+func dist(a, b image.Point) float64 {
+	d := a.Sub(b)
+	return math.Hypot(float64(d.X), float64(d.Y))
+}
+
+func (b *BallAnalyzer) Close() error {
+	return nil
+}
+
+// BallOverlay draws the target line from the ball, the ball's path after impact and its launch angles.
+type BallOverlay struct {
+	results *clipResults[ShotClip]
+}
+
+func NewBallOverlay(analysis *Analysis, camera string) *BallOverlay {
+	return &BallOverlay{results: newClipInfoResults(analysis, camera, BallAnalysis)}
+}
+
+func (o *BallOverlay) Draw(img *gocv.Mat, frame PlaybackFrame) {
+	if frame.Live {
+		return
+	}
+	info := o.results.get(frame.Shot)
+	if info == nil || info.Launch == nil || len(info.Launch.Path) < 2 {
+		return
+	}
+	launch := info.Launch
+	ball := launch.Path[0]
+	gocv.Line(img, ball.Point(), launch.Target.Point(), ballTargetColor, 1)
+	gocv.Circle(img, ball.Point(), int(ball.Radius), ballPathColor, 1)
+	if frame.Index < launch.Path[1].Frame {
+		return
+	}
+	for i := 1; i < len(launch.Path) && launch.Path[i].Frame <= frame.Index; i++ {
+		gocv.Line(img, launch.Path[i-1].Point(), launch.Path[i].Point(), ballPathColor, 2)
+	}
+	side := "R"
+	if launch.Horizontal < 0 {
+		side = "L"
+	}
+	text := fmt.Sprintf("launch %.1f %s, %.1f up", math.Abs(launch.Horizontal), side, launch.Vertical)
+	gocv.PutText(img, text, ball.Point().Add(image.Pt(int(ball.Radius)+10, 0)), gocv.FontHersheySimplex, 0.6, ballPathColor, 2)
+}
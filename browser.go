@@ -0,0 +1,218 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+	"sync"
+
+	"gocv.io/x/gocv"
+)
+
+const (
+	// number of shots listed around the one being replayed
+	DefaultBrowserListLength = 15
+)
+
+// browserCommands maps commands to browsing the shot library.
+var browserCommands = map[string]func(b *Browser){
+	"next_shot":        (*Browser).Next,
+	"previous_shot":    (*Browser).Previous,
+	"shot_list":        (*Browser).ToggleList,
+	"delete_last_shot": (*Browser).DeleteReplayed,
+}
+
+// Browser steps through the saved shots, loading them from disk into the synchronised replay,
+// and draws a list of shots over the playback windows.
+type Browser struct {
+	library  *Library
+	playback *PlaybackController
+	cameras  []string
+	windows  []*VideoPlaybackWindow
+
+	mu       sync.Mutex
+	shots    []*Shot
+	showList bool
+	loading  bool
+}
+
+func NewBrowser(library *Library, playback *PlaybackController, cameras []string, windows []*VideoPlaybackWindow) *Browser {
+	return &Browser{
+		library:  library,
+		playback: playback,
+		cameras:  cameras,
+		windows:  windows,
+	}
+}
+
+// RegisterCommands browses and edits the library with the commands of keys pressed in any window.
+func (b *Browser) RegisterCommands(d *CommandDispatcher) {
+	for name, fn := range browserCommands {
+		d.Handle(name, func(string) { fn(b) })
+	}
+	d.Handle("tag", b.ToggleTag)
+}
+
+// Next replays the shot saved after the one being replayed.
+func (b *Browser) Next() {
+	go b.step(1)
+}
+
+// Previous replays the shot saved before the one being replayed.
+func (b *Browser) Previous() {
+	go b.step(-1)
+}
+
+// ToggleList shows or hides the list of shots.
+func (b *Browser) ToggleList() {
+	b.mu.Lock()
+	b.showList = !b.showList
+	showList := b.showList
+	b.mu.Unlock()
+
+	if showList {
+		go func() {
+			b.refreshShots()
+			b.refreshWindows()
+		}()
+		return
+	}
+	b.refreshWindows()
+}
+
+// DeleteReplayed deletes the shot being replayed, usually the one just hit, favourites are kept.
+// A shot still being saved is deleted once its clips are written.
+func (b *Browser) DeleteReplayed() {
+	shot := b.playback.Shot()
+	if shot == nil {
+		fmt.Println("no shot is being replayed, nothing to delete")
+		return
+	}
+	go func() {
+		shot.WaitSaved()
+		if shot.Favorite {
+			fmt.Printf("not deleting favourite shot %s\n", shot.ID)
+			return
+		}
+		if err := b.library.Delete(shot); err != nil {
+			fmt.Printf("error deleting shot: %v\n", err)
+			return
+		}
+		fmt.Printf(">>>>>>>> deleted shot %s\n", shot.ID)
+		b.refreshShots()
+		b.refreshWindows()
+	}()
+}
+
+// ToggleTag adds the tag to the shot being replayed, or removes it if the shot already has it.
+func (b *Browser) ToggleTag(tag string) {
+	shot := b.playback.Shot()
+	if shot == nil {
+		return
+	}
+	go func() {
+		// tagging saves shot.json, which must not be written before the clips are
+		shot.WaitSaved()
+		tagged, err := shot.ToggleTag(tag)
+		if err != nil {
+			fmt.Printf("error tagging shot: %v\n", err)
+			return
+		}
+		fmt.Printf("shot %s tagged %s: %t\n", shot.ID, tag, tagged)
+		b.refreshShots()
+		b.refreshWindows()
+	}()
+}
+
+func (b *Browser) step(direction int) {
+	b.mu.Lock()
+	if b.loading {
+		b.mu.Unlock()
+		return
+	}
+	b.loading = true
+	b.mu.Unlock()
+	defer func() {
+		b.mu.Lock()
+		b.loading = false
+		b.mu.Unlock()
+	}()
+
+	shots := b.refreshShots()
+	if len(shots) == 0 {
+		return
+	}
+	// without a saved shot being replayed, start browsing from the latest shot
+	idx := len(shots)
+	if current := b.playback.Shot(); current != nil {
+		for i, shot := range shots {
+			if shot.ID == current.ID {
+				idx = i
+			}
+		}
+	}
+	idx = min(max(idx+direction, 0), len(shots)-1)
+
+	shot := shots[idx]
+	fmt.Printf(">>>>>>>> loading shot %s\n", shot.ID)
+	b.playback.Play(LoadReplay(shot, b.cameras))
+}
+
+func (b *Browser) refreshShots() []*Shot {
+	shots, err := b.library.Shots()
+	if err != nil {
+		fmt.Printf("error listing shots: %v\n", err)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.shots = shots
+	return shots
+}
+
+func (b *Browser) refreshWindows() {
+	for _, w := range b.windows {
+		w.Refresh()
+	}
+}
+
+// Draw lists the shots around the one being replayed, newest at the top.
+func (b *Browser) Draw(img *gocv.Mat, frame PlaybackFrame) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !b.showList || len(b.shots) == 0 {
+		return
+	}
+
+	current := len(b.shots) - 1
+	if frame.Shot != nil {
+		for i, shot := range b.shots {
+			if shot.ID == frame.Shot.ID {
+				current = i
+			}
+		}
+	}
+	first := min(len(b.shots)-1, current+DefaultBrowserListLength/2)
+	last := max(0, first-DefaultBrowserListLength+1)
+
+	const lineHeight = 28
+	gocv.Rectangle(img, image.Rect(0, 0, 520, (first-last+1)*lineHeight+16), color.RGBA{A: 255}, -1)
+	y := lineHeight
+	for i := first; i >= last; i-- {
+		shot := b.shots[i]
+		line := fmt.Sprintf("%s  %s", shot.ImpactTime.Format("Jan 02 15:04:05"), shot.Club)
+		if len(shot.Tags) > 0 {
+			line += "  [" + strings.Join(shot.Tags, ", ") + "]"
+		}
+		if shot.Favorite {
+			line += "  *"
+		}
+		c := color.RGBA{R: 200, G: 200, B: 200, A: 255}
+		if i == current {
+			line = "> " + line
+			c = color.RGBA{R: 255, G: 255, A: 255}
+		}
+		gocv.PutText(img, line, image.Pt(10, y), gocv.FontHersheySimplex, 0.6, c, 1)
+		y += lineHeight
+	}
+}
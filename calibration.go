@@ -0,0 +1,254 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+const (
+	// directory the calibration of each camera is saved in
+	DefaultCalibrationDir = "calibration"
+	// inner corners of the common 10x7 squares checkerboard
+	DefaultCalibrationBoardColumns = 9
+	DefaultCalibrationBoardRows    = 6
+	// views of the board captured before calibrating, from different angles and parts of the frame
+	DefaultCalibrationViews = 15
+
+	// frames are undistorted as they are captured, so saved clips and their analyses are corrected too
+	UndistortCapture = "capture"
+	// only the replays and live view are undistorted, saved clips keep the camera's picture
+	UndistortPlayback = "playback"
+)
+
+// CalibrationConfig sets up lens calibration with a printed checkerboard, and undistortion with its result.
+type CalibrationConfig struct {
+	Dir string `json:"dir"`
+	// inner corners of the checkerboard along each side
+	BoardColumns int `json:"board_columns"`
+	BoardRows    int `json:"board_rows"`
+	Views        int `json:"views"`
+	// "capture", "playback" or empty to leave frames as the camera captured them
+	Undistort string `json:"undistort"`
+}
+
+func DefaultCalibrationConfig() CalibrationConfig {
+	return CalibrationConfig{
+		Dir:          DefaultCalibrationDir,
+		BoardColumns: DefaultCalibrationBoardColumns,
+		BoardRows:    DefaultCalibrationBoardRows,
+		Views:        DefaultCalibrationViews,
+	}
+}
+
+// calibrationFile is the camera's calibration in the directory, written with gocv.FileStorage.
+// It is written as json, as the vendored FileStorage bindings can't read matrices back.
+func calibrationFile(dir, camera string) string {
+	return filepath.Join(dir, camera+".json")
+}
+
+// Calibrate shows the camera's live view and captures views of the checkerboard with the space key,
+// then computes the camera's intrinsics and saves them.
+func Calibrate(cfg Config, name string) error {
+	var camCfg *CameraConfig
+	for i := range cfg.Cameras {
+		if cfg.Cameras[i].Name == name {
+			camCfg = &cfg.Cameras[i]
+		}
+	}
+	if camCfg == nil {
+		return fmt.Errorf("no camera named %s in the config", name)
+	}
+	cam, _, err := openCamera(*camCfg, cfg.Capture)
+	if err != nil {
+		return err
+	}
+	defer cam.Close()
+	window := gocv.NewWindow("calibrate " + name)
+	defer window.Close()
+
+	board := image.Pt(cfg.Calibration.BoardColumns, cfg.Calibration.BoardRows)
+	objectPoints := gocv.NewPoints3fVector()
+	defer objectPoints.Close()
+	imagePoints := gocv.NewPoints2fVector()
+	defer imagePoints.Close()
+	var corners3d []gocv.Point3f
+	for y := 0; y < board.Y; y++ {
+		for x := 0; x < board.X; x++ {
+			corners3d = append(corners3d, gocv.NewPoint3f(float32(x), float32(y), 0))
+		}
+	}
+
+	read, frame, gray, corners := gocv.NewMat(), gocv.NewMat(), gocv.NewMat(), gocv.NewMat()
+	defer read.Close()
+	defer frame.Close()
+	defer gray.Close()
+	defer corners.Close()
+	fmt.Printf(">>>>>>>> calibrating %s, hold a %dx%d corner checkerboard in view and press space to capture it\n", name, board.X, board.Y)
+	var views int
+	lastRead := time.Now()
+	for views < cfg.Calibration.Views {
+		if ok := cam.Read(&read); !ok || read.Empty() {
+			if time.Since(lastRead) > DefaultCameraReadTimeout {
+				return fmt.Errorf("no frames from camera %s for %s", name, DefaultCameraReadTimeout)
+			}
+			time.Sleep(DefaultCameraReadRetryDelay)
+			continue
+		}
+		lastRead = time.Now()
+		// the same orientation as captured frames
+		gocv.Rotate(read, &frame, gocv.Rotate180Clockwise)
+		gocv.CvtColor(frame, &gray, gocv.ColorBGRToGray)
+		found := gocv.FindChessboardCorners(gray, board, &corners, gocv.CalibCBAdaptiveThresh|gocv.CalibCBNormalizeImage|gocv.CalibCBFastCheck)
+		if !corners.Empty() {
+			gocv.DrawChessboardCorners(&frame, board, corners, found)
+		}
+		text := fmt.Sprintf("views %d/%d, space to capture, esc to cancel", views, cfg.Calibration.Views)
+		gocv.PutText(&frame, text, image.Pt(10, 30), gocv.FontHersheySimplex, 0.7, color.RGBA{G: 255, A: 255}, 2)
+		window.IMShow(frame)
+
+		switch window.WaitKey(1) {
+		case 27:
+			return fmt.Errorf("calibration cancelled")
+		case ' ':
+			if !found {
+				continue
+			}
+			gocv.CornerSubPix(gray, &corners, image.Pt(11, 11), image.Pt(-1, -1), gocv.NewTermCriteria(gocv.Count|gocv.EPS, 30, 0.001))
+			objectView := gocv.NewPoint3fVectorFromPoints(corners3d)
+			objectPoints.Append(objectView)
+			objectView.Close()
+			imageView := gocv.NewPoint2fVectorFromMat(corners)
+			imagePoints.Append(imageView)
+			imageView.Close()
+			views++
+		}
+	}
+
+	size := image.Pt(frame.Cols(), frame.Rows())
+	cameraMatrix, distortion, rvecs, tvecs := gocv.NewMat(), gocv.NewMat(), gocv.NewMat(), gocv.NewMat()
+	defer cameraMatrix.Close()
+	defer distortion.Close()
+	defer rvecs.Close()
+	defer tvecs.Close()
+	rms := gocv.CalibrateCamera(objectPoints, imagePoints, size, &cameraMatrix, &distortion, &rvecs, &tvecs, 0)
+	fmt.Printf(">>>>>>>> calibrated %s with a reprojection error of %.2f px\n", name, rms)
+
+	if err := os.MkdirAll(cfg.Calibration.Dir, 0o755); err != nil {
+		return fmt.Errorf("error creating calibration directory: %w", err)
+	}
+	file := calibrationFile(cfg.Calibration.Dir, name)
+	fs := gocv.NewFileStorageWithParams(file, gocv.FileStorageModeWrite|gocv.FileStorageModeFormatJson, "")
+	defer fs.Close()
+	if !fs.IsOpened() {
+		return fmt.Errorf("error writing calibration %s", file)
+	}
+	fs.WriteInt("image_width", size.X)
+	fs.WriteInt("image_height", size.Y)
+	fs.WriteDouble("rms", float32(rms))
+	fs.WriteMat("camera_matrix", cameraMatrix)
+	fs.WriteMat("distortion_coefficients", distortion)
+	fmt.Printf("calibration saved to %s\n", file)
+	return nil
+}
+
+// openCVMatrix is a matrix as FileStorage writes it to json.
+type openCVMatrix struct {
+	Rows int       `json:"rows"`
+	Cols int       `json:"cols"`
+	Data []float64 `json:"data"`
+}
+
+func (m openCVMatrix) mat() (gocv.Mat, error) {
+	if m.Rows*m.Cols == 0 || len(m.Data) != m.Rows*m.Cols {
+		return gocv.Mat{}, fmt.Errorf("invalid %dx%d matrix with %d values", m.Rows, m.Cols, len(m.Data))
+	}
+	mat := gocv.NewMatWithSize(m.Rows, m.Cols, gocv.MatTypeCV64F)
+	for i, v := range m.Data {
+		mat.SetDoubleAt(i/m.Cols, i%m.Cols, v)
+	}
+	return mat, nil
+}
+
+// Undistorter corrects a camera's lens distortion with maps computed once from its calibration.
+type Undistorter struct {
+	camera     string
+	size       image.Point
+	map1, map2 gocv.Mat
+}
+
+// LoadUndistorter loads the camera's calibration from the directory.
+func LoadUndistorter(dir, camera string) (*Undistorter, error) {
+	b, err := os.ReadFile(calibrationFile(dir, camera))
+	if err != nil {
+		return nil, fmt.Errorf("error reading calibration of %s: %w", camera, err)
+	}
+	var calibration struct {
+		Width        int          `json:"image_width"`
+		Height       int          `json:"image_height"`
+		CameraMatrix openCVMatrix `json:"camera_matrix"`
+		Distortion   openCVMatrix `json:"distortion_coefficients"`
+	}
+	if err := json.Unmarshal(b, &calibration); err != nil {
+		return nil, fmt.Errorf("error parsing calibration of %s: %w", camera, err)
+	}
+	cameraMatrix, err := calibration.CameraMatrix.mat()
+	if err != nil {
+		return nil, fmt.Errorf("error parsing camera matrix of %s: %w", camera, err)
+	}
+	defer cameraMatrix.Close()
+	distortion, err := calibration.Distortion.mat()
+	if err != nil {
+		return nil, fmt.Errorf("error parsing distortion of %s: %w", camera, err)
+	}
+	defer distortion.Close()
+
+	// keep only valid pixels, so the corrected frames have no black border
+	size := image.Pt(calibration.Width, calibration.Height)
+	newMatrix, _ := gocv.GetOptimalNewCameraMatrixWithParams(cameraMatrix, distortion, size, 0, size, false)
+	defer newMatrix.Close()
+	rotation := gocv.NewMat()
+	defer rotation.Close()
+	u := &Undistorter{camera: camera, size: size, map1: gocv.NewMat(), map2: gocv.NewMat()}
+	gocv.InitUndistortRectifyMap(cameraMatrix, distortion, rotation, newMatrix, size, int(gocv.MatTypeCV16SC2), u.map1, u.map2)
+	return u, nil
+}
+
+// Undistort corrects src into dst and reports whether it did, frames of another size than calibrated are copied unchanged.
+func (u *Undistorter) Undistort(src gocv.Mat, dst *gocv.Mat) bool {
+	if src.Cols() != u.size.X || src.Rows() != u.size.Y {
+		src.CopyTo(dst)
+		return false
+	}
+	gocv.Remap(src, dst, &u.map1, &u.map2, gocv.InterpolationLinear, gocv.BorderConstant, color.RGBA{})
+	return true
+}
+
+// Source returns the point of the camera's picture shown at pt of an undistorted frame, to the nearest pixel.
+func (u *Undistorter) Source(pt image.Point) image.Point {
+	if !pt.In(image.Rectangle{Max: u.size}) {
+		return pt
+	}
+	// map1 holds the whole pixel of the source for each pixel of the undistorted frame
+	return image.Pt(int(u.map1.GetShortAt(pt.Y, 2*pt.X)), int(u.map1.GetShortAt(pt.Y, 2*pt.X+1)))
+}
+
+// needed reports whether a frame being played still has to be undistorted, clips saved undistorted don't.
+func (u *Undistorter) needed(frame PlaybackFrame) bool {
+	if frame.Live || frame.Shot == nil {
+		return true
+	}
+	info, ok := frame.Shot.Clip(u.camera)
+	return !ok || !info.Undistorted
+}
+
+func (u *Undistorter) Close() {
+	u.map1.Close()
+	u.map2.Close()
+}
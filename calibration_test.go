@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestOpenCVMatrixMat(t *testing.T) {
+	tests := []struct {
+		name   string
+		matrix openCVMatrix
+		err    bool
+	}{
+		{name: "camera matrix", matrix: openCVMatrix{Rows: 3, Cols: 3, Data: []float64{800, 0, 320, 0, 800, 240, 0, 0, 1}}},
+		{name: "distortion coefficients", matrix: openCVMatrix{Rows: 1, Cols: 5, Data: []float64{-0.2, 0.05, 0.001, -0.002, 0}}},
+		{name: "empty", matrix: openCVMatrix{}, err: true},
+		{name: "no rows", matrix: openCVMatrix{Rows: 0, Cols: 3, Data: []float64{}}, err: true},
+		{name: "too few values", matrix: openCVMatrix{Rows: 3, Cols: 3, Data: []float64{1, 2, 3}}, err: true},
+		{name: "too many values", matrix: openCVMatrix{Rows: 1, Cols: 2, Data: []float64{1, 2, 3}}, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mat, err := tt.matrix.mat()
+			if tt.err {
+				if err == nil {
+					mat.Close()
+					t.Fatal("mat succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("mat: %v", err)
+			}
+			defer mat.Close()
+			if mat.Rows() != tt.matrix.Rows || mat.Cols() != tt.matrix.Cols {
+				t.Fatalf("mat is %dx%d, want %dx%d", mat.Rows(), mat.Cols(), tt.matrix.Rows, tt.matrix.Cols)
+			}
+			for i, want := range tt.matrix.Data {
+				if got := mat.GetDoubleAt(i/tt.matrix.Cols, i%tt.matrix.Cols); got != want {
+					t.Errorf("value %d = %f, want %f", i, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+const (
+	// a camera that returns no frames for this long is considered disconnected
+	DefaultCameraReadTimeout = time.Second
+	// wait between failed reads, so a glitching camera doesn't busy loop
+	DefaultCameraReadRetryDelay = 5 * time.Millisecond
+	// backoff between reconnect attempts doubles up to the max
+	DefaultCameraReconnectBackoff    = 500 * time.Millisecond
+	DefaultCameraMaxReconnectBackoff = 10 * time.Second
+	// the camera is reported as failed after this many attempts, but reconnecting continues
+	DefaultCameraMaxReconnectAttempts = 5
+)
+
+type CameraState string
+
+const (
+	CameraLive         CameraState = "live"
+	CameraReconnecting CameraState = "reconnecting"
+	CameraFailed       CameraState = "failed"
+)
+
+// CameraControl is a camera setting as requested in the config and as accepted by the driver.
+type CameraControl struct {
+	Name      string  `json:"name"`
+	Requested float64 `json:"requested"`
+	Accepted  float64 `json:"accepted"`
+}
+
+// openCamera opens the camera device and applies the capture settings and camera controls.
+func openCamera(camCfg CameraConfig, cfg CaptureConfig) (*gocv.VideoCapture, []CameraControl, error) {
+	cam, err := gocv.VideoCaptureDevice(camCfg.Device)
+	if err != nil {
+		cam.Close()
+		return nil, nil, fmt.Errorf("error opening %s camera (%d): %w", camCfg.Name, camCfg.Device, err)
+	}
+
+	cam.Set(gocv.VideoCaptureFrameWidth, float64(cfg.Width))
+	cam.Set(gocv.VideoCaptureFrameHeight, float64(cfg.Height))
+	cam.Set(gocv.VideoCaptureFPS, cfg.FPS)
+
+	// auto modes are applied before the manual values they'd otherwise override
+	controls := []struct {
+		name  string
+		prop  gocv.VideoCaptureProperties
+		value *float64
+	}{
+		{"auto exposure", gocv.VideoCaptureAutoExposure, camCfg.AutoExposure},
+		{"exposure", gocv.VideoCaptureExposure, camCfg.Exposure},
+		{"gain", gocv.VideoCaptureGain, camCfg.Gain},
+		{"auto focus", gocv.VideoCaptureAutoFocus, boolControl(camCfg.AutoFocus)},
+		{"focus", gocv.VideoCaptureFocus, camCfg.Focus},
+		{"auto white balance", gocv.VideoCaptureAutoWB, boolControl(camCfg.AutoWhiteBalance)},
+		{"white balance", gocv.VideoCaptureWBTemperature, camCfg.WhiteBalance},
+	}
+	var accepted []CameraControl
+	for _, c := range controls {
+		if c.value == nil {
+			continue
+		}
+		cam.Set(c.prop, *c.value)
+		accepted = append(accepted, CameraControl{
+			Name:      c.name,
+			Requested: *c.value,
+			Accepted:  cam.Get(c.prop),
+		})
+	}
+
+	// Retrieve camera properties
+	width := cam.Get(gocv.VideoCaptureFrameWidth)
+	height := cam.Get(gocv.VideoCaptureFrameHeight)
+	fps := cam.Get(gocv.VideoCaptureFPS)
+
+	// Print camera details
+	fmt.Println("==================================================")
+	fmt.Printf("Camera Details %s (device=%d):\n", camCfg.Name, camCfg.Device)
+	fmt.Printf("Resolution: %.0fx%.0f\n", width, height)
+	fmt.Printf("FPS: %.2f\n", fps)
+	for _, c := range accepted {
+		if c.Accepted != c.Requested {
+			fmt.Printf("%s: %g (requested %g, not accepted by the driver)\n", c.Name, c.Accepted, c.Requested)
+			continue
+		}
+		fmt.Printf("%s: %g\n", c.Name, c.Accepted)
+	}
+	fmt.Println("==================================================")
+
+	return cam, accepted, nil
+}
+
+// boolControl converts an on/off control to the 1/0 value expected by the driver.
+func boolControl(b *bool) *float64 {
+	if b == nil {
+		return nil
+	}
+	v := 0.0
+	if *b {
+		v = 1
+	}
+	return &v
+}
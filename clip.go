@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"sync/atomic"
+
+	"gocv.io/x/gocv"
+)
+
+// Clip is a camera's recording held in memory, indexed by frame so seeking and stepping backwards are instant.
+// Clips are reference counted, the frames are closed once the last holder releases the clip.
+type Clip struct {
+	Camera      string
+	FPS         float64
+	ImpactFrame int
+
+	frames []*sharedFrame
+	refs   atomic.Int32
+}
+
+// sharedFrame is a frame held by both the capture buffer and the clips taken from it, it is closed by the last holder.
+// Shared frames are never modified.
+type sharedFrame struct {
+	mat  gocv.Mat
+	refs atomic.Int32
+}
+
+// newSharedFrame takes ownership of mat, the returned frame holds one reference.
+func newSharedFrame(mat gocv.Mat) *sharedFrame {
+	f := &sharedFrame{mat: mat}
+	f.refs.Store(1)
+	return f
+}
+
+func (f *sharedFrame) retain() *sharedFrame {
+	f.refs.Add(1)
+	return f
+}
+
+func (f *sharedFrame) release() {
+	if f.refs.Add(-1) == 0 {
+		f.mat.Close()
+	}
+}
+
+// NewClip takes ownership of frames, the returned clip holds one reference.
+func NewClip(camera string, frames []gocv.Mat, fps float64, impactFrame int) *Clip {
+	shared := make([]*sharedFrame, len(frames))
+	for i, frame := range frames {
+		shared[i] = newSharedFrame(frame)
+	}
+	return newSharedClip(camera, shared, fps, impactFrame)
+}
+
+// newSharedClip takes over a reference to each of the frames, the returned clip holds one reference.
+func newSharedClip(camera string, frames []*sharedFrame, fps float64, impactFrame int) *Clip {
+	c := &Clip{
+		Camera:      camera,
+		FPS:         fps,
+		ImpactFrame: impactFrame,
+		frames:      frames,
+	}
+	c.refs.Store(1)
+	return c
+}
+
+// LoadClip decodes the clip a camera recorded for a saved shot.
+func LoadClip(shot *Shot, camera string) (*Clip, error) {
+	info, ok := shot.Clip(camera)
+	if !ok {
+		return nil, fmt.Errorf("shot %s has no %s clip", shot.ID, camera)
+	}
+	file := shot.Path(info.File)
+	video, err := gocv.VideoCaptureFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("error opening video file %s: %w", file, err)
+	}
+	defer video.Close()
+
+	var frames []gocv.Mat
+	for {
+		frame := gocv.NewMat()
+		if ok := video.Read(&frame); !ok || frame.Empty() {
+			frame.Close()
+			break
+		}
+		frames = append(frames, frame)
+	}
+	if len(frames) == 0 {
+		return nil, fmt.Errorf("no frames in video file %s", file)
+	}
+	return NewClip(camera, frames, info.PlaybackFPS(), min(info.ImpactFrame, len(frames)-1)), nil
+}
+
+// LoadReplay decodes the clips of a saved shot for replay, cameras the shot has no clip for are nil.
+func LoadReplay(shot *Shot, cameras []string) Replay {
+	replay := Replay{Shot: shot, Clips: make([]*Clip, len(cameras))}
+	for i, camera := range cameras {
+		clip, err := LoadClip(shot, camera)
+		if err != nil {
+			fmt.Printf("error loading %s clip of shot %s: %v\n", camera, shot.ID, err)
+			continue
+		}
+		replay.Clips[i] = clip
+	}
+	return replay
+}
+
+func (c *Clip) Len() int {
+	return len(c.frames)
+}
+
+// Frame returns frame i, it must not be modified and is only valid while the clip is held.
+func (c *Clip) Frame(i int) gocv.Mat {
+	return c.frames[i].mat
+}
+
+// Save encodes the clip to a video file.
+func (c *Clip) Save(file string, width, height int, fps float64) (err error) {
+	videoWriter, err := gocv.VideoWriterFile(file, "MJPG", fps, width, height, true)
+	if err != nil {
+		return fmt.Errorf("error creating video writer: %w", err)
+	}
+	defer videoWriter.Close()
+
+	for idx, frame := range c.frames {
+		err = videoWriter.Write(frame.mat)
+		if err != nil {
+			return fmt.Errorf("error writing frame (%d): %w", idx, err)
+		}
+	}
+
+	return nil
+}
+
+// Copy returns a clip of the same frames with its own impact frame, e.g. so realigning a replay doesn't move
+// the reference taken from it. The copy holds one reference and is released separately.
+func (c *Clip) Copy() *Clip {
+	frames := make([]*sharedFrame, len(c.frames))
+	for i, frame := range c.frames {
+		frames[i] = frame.retain()
+	}
+	return newSharedClip(c.Camera, frames, c.FPS, c.ImpactFrame)
+}
+
+// Retain adds a reference to the clip.
+func (c *Clip) Retain() *Clip {
+	c.refs.Add(1)
+	return c
+}
+
+// Release drops a reference to the clip, closing its frames when it was the last one.
+func (c *Clip) Release() {
+	if c.refs.Add(-1) > 0 {
+		return
+	}
+	for _, frame := range c.frames {
+		frame.release()
+	}
+	c.frames = nil
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+
+	"gocv.io/x/gocv"
+)
+
+const (
+	// name of the club head tracking analysis and its results files
+	ClubAnalysis = "club"
+	// size of the box the fallback tracker follows around the club head
+	DefaultClubTrackerBox = 48
+	// optical flow with a higher error is treated as lost
+	DefaultClubMaxFlowError = 30
+	// frames the club head is predicted through when both trackers lose it, before the track ends
+	DefaultClubMaxPredictedFrames = 6
+	// the seed is the strongest motion around the impact frame, weaker motion means the club wasn't found
+	DefaultClubMinMotion = 25
+)
+
+var (
+	clubBackswingColor = color.RGBA{R: 255, G: 200, A: 255}
+	clubDownswingColor = color.RGBA{R: 255, G: 60, B: 60, A: 255}
+	clubFollowColor    = color.RGBA{R: 60, G: 200, B: 255, A: 255}
+)
+
+// ClubConfig enables club head tracking on saved shots, it is off unless enabled in the config.
+type ClubConfig struct {
+	Enabled    bool `json:"enabled"`
+	TrackerBox int  `json:"tracker_box"`
+}
+
+func DefaultClubConfig() ClubConfig {
+	return ClubConfig{
+		TrackerBox: DefaultClubTrackerBox,
+	}
+}
+
+// ClubPoint is the club head position in a frame.
+type ClubPoint struct {
+	Frame int `json:"frame"`
+	X     int `json:"x"`
+	Y     int `json:"y"`
+	// neither tracker found the club head, the position was predicted from its motion
+	Predicted bool `json:"predicted,omitempty"`
+}
+
+func (p ClubPoint) Point() image.Point {
+	return image.Pt(p.X, p.Y)
+}
+
+// ClubTrack is the club head path through a clip, tracked forwards and backwards from the seed.
+type ClubTrack struct {
+	SeedFrame int `json:"seed_frame"`
+	// the seed was clicked rather than detected
+	Manual bool `json:"manual"`
+	// positions ordered by frame
+	Points []ClubPoint `json:"points"`
+}
+
+// Top returns the index of the top of the backswing in Points, where the club head is highest before impact, or -1.
+func (t *ClubTrack) Top(impact int) int {
+	top := -1
+	for i, p := range t.Points {
+		if p.Frame < impact && (top < 0 || p.Y < t.Points[top].Y) {
+			top = i
+		}
+	}
+	return top
+}
+
+// ClubAnalyzer follows the club head through a clip with optical flow, falling back to a tracker when the flow is lost,
+// and smooths the path with a Kalman filter.
+type ClubAnalyzer struct {
+	cfg ClubConfig
+}
+
+func NewClubAnalyzer(cfg ClubConfig) *ClubAnalyzer {
+	cfg.TrackerBox = max(cfg.TrackerBox, 8)
+	return &ClubAnalyzer{cfg: cfg}
+}
+
+func (c *ClubAnalyzer) Name() string {
+	return ClubAnalysis
+}
+
+// Analyze seeds the track with the strongest motion around the impact frame, where the club head moves fastest.
+// A track seeded by hand before the shot was analysed is kept.
+func (c *ClubAnalyzer) Analyze(shot *Shot, clip *Clip, info *ShotClip) error {
+	var seeded ClubTrack
+	if err := loadClipData(shot, clip.Camera, ClubAnalysis, &seeded); err == nil && seeded.Manual {
+		return nil
+	}
+	impact := clip.ImpactFrame
+	if impact < 1 || impact >= clip.Len()-1 {
+		return fmt.Errorf("impact frame %d is at the edge of the clip", impact)
+	}
+	before, after := grayFrame(clip.Frame(impact-1)), grayFrame(clip.Frame(impact+1))
+	defer before.Close()
+	defer after.Close()
+	diff := gocv.NewMat()
+	defer diff.Close()
+	gocv.AbsDiff(before, after, &diff)
+	gocv.GaussianBlur(diff, &diff, image.Pt(15, 15), 0, 0, gocv.BorderDefault)
+	_, motion, _, seed := gocv.MinMaxLoc(diff)
+	if motion < DefaultClubMinMotion {
+		return fmt.Errorf("no club motion found at impact")
+	}
+	return c.Track(shot, clip, impact, seed, false)
+}
+
+// Track follows the club head from the seed on frame seedFrame and saves the track with the shot.
+func (c *ClubAnalyzer) Track(shot *Shot, clip *Clip, seedFrame int, seed image.Point, manual bool) error {
+	if seedFrame < 0 || seedFrame >= clip.Len() {
+		return fmt.Errorf("seed frame %d is not in the clip", seedFrame)
+	}
+	frames := newGrayFrames(clip)
+	defer frames.Close()
+
+	backward := c.follow(clip, frames, seedFrame, seed, -1)
+	forward := c.follow(clip, frames, seedFrame, seed, 1)
+	track := ClubTrack{SeedFrame: seedFrame, Manual: manual}
+	for i := len(backward) - 1; i > 0; i-- {
+		track.Points = append(track.Points, backward[i])
+	}
+	track.Points = append(track.Points, forward...)
+	return saveClipData(shot, clip.Camera, ClubAnalysis, track)
+}
+
+// follow tracks the club head from the seed in one direction until it is lost or the clip ends.
+func (c *ClubAnalyzer) follow(clip *Clip, frames *grayFrames, seedFrame int, seed image.Point, step int) []ClubPoint {
+	filter := newPointFilter(seed)
+	defer filter.Close()
+	tracker := gocv.NewTrackerMIL()
+	defer tracker.Close()
+	first := clip.Frame(0)
+	bounds := image.Rect(0, 0, first.Cols(), first.Rows())
+	box := func(pt image.Point) image.Rectangle {
+		half := c.cfg.TrackerBox / 2
+		return image.Rect(pt.X-half, pt.Y-half, pt.X+half, pt.Y+half).Intersect(bounds)
+	}
+	tracking := tracker.Init(clip.Frame(seedFrame), box(seed))
+
+	points := []ClubPoint{{Frame: seedFrame, X: seed.X, Y: seed.Y}}
+	pt := seed
+	var predicted int
+	for i := seedFrame + step; i >= 0 && i < clip.Len(); i += step {
+		prediction := filter.Predict()
+		measured, ok := flowPoint(frames.Frame(i-step), frames.Frame(i), pt)
+
+		// the tracker follows every frame so it is ready when the flow is lost
+		if tracking {
+			rect, found := tracker.Update(clip.Frame(i))
+			center := rect.Min.Add(rect.Size().Div(2))
+			switch {
+			case !ok && found:
+				measured, ok = center, true
+			case ok && (!found || !center.In(box(measured))):
+				// the tracker drifted off the club head, restart it where the flow found it
+				tracking = tracker.Init(clip.Frame(i), box(measured))
+			}
+		}
+
+		if ok {
+			pt, predicted = filter.Correct(measured), 0
+		} else {
+			if predicted++; predicted > DefaultClubMaxPredictedFrames {
+				break
+			}
+			pt = prediction
+		}
+		points = append(points, ClubPoint{Frame: i, X: pt.X, Y: pt.Y, Predicted: !ok})
+	}
+	return points
+}
+
+// flowPoint follows a point from one frame to the next with pyramidal Lucas-Kanade optical flow.
+func flowPoint(prev, next gocv.Mat, pt image.Point) (image.Point, bool) {
+	prevPts := gocv.NewMatWithSize(1, 1, gocv.MatTypeCV32FC2)
+	defer prevPts.Close()
+	prevPts.SetFloatAt(0, 0, float32(pt.X))
+	prevPts.SetFloatAt(0, 1, float32(pt.Y))
+	nextPts, status, flowErr := gocv.NewMat(), gocv.NewMat(), gocv.NewMat()
+	defer nextPts.Close()
+	defer status.Close()
+	defer flowErr.Close()
+
+	gocv.CalcOpticalFlowPyrLK(prev, next, prevPts, nextPts, &status, &flowErr)
+	if status.Empty() || status.GetUCharAt(0, 0) == 0 || flowErr.GetFloatAt(0, 0) > DefaultClubMaxFlowError {
+		return image.Point{}, false
+	}
+	v := nextPts.GetVecfAt(0, 0)
+	found := image.Pt(int(v[0]), int(v[1]))
+	if !found.In(image.Rect(0, 0, next.Cols(), next.Rows())) {
+		return image.Point{}, false
+	}
+	return found, true
+}
+
+func grayFrame(frame gocv.Mat) gocv.Mat {
+	gray := gocv.NewMat()
+	gocv.CvtColor(frame, &gray, gocv.ColorBGRToGray)
+	return gray
+}
+
+// grayFrames converts a clip's frames to grayscale as they are needed.
+type grayFrames struct {
+	clip   *Clip
+	frames map[int]gocv.Mat
+}
+
+func newGrayFrames(clip *Clip) *grayFrames {
+	return &grayFrames{clip: clip, frames: make(map[int]gocv.Mat)}
+}
+
+func (g *grayFrames) Frame(i int) gocv.Mat {
+	frame, ok := g.frames[i]
+	if !ok {
+		frame = grayFrame(g.clip.Frame(i))
+		g.frames[i] = frame
+	}
+	return frame
+}
+
+func (g *grayFrames) Close() {
+	for _, frame := range g.frames {
+		frame.Close()
+	}
+}
+
+// pointFilter smooths a tracked point with a constant velocity Kalman filter.
+type pointFilter struct {
+	kf          gocv.KalmanFilter
+	measurement gocv.Mat
+}
+
+func newPointFilter(start image.Point) *pointFilter {
+	// state is x, y, dx, dy and the measurement is x, y
+	kf := gocv.NewKalmanFilter(4, 2)
+	for _, m := range []struct {
+		set  func(gocv.Mat)
+		rows [][]float32
+	}{
+		{kf.SetTransitionMatrix, [][]float32{{1, 0, 1, 0}, {0, 1, 0, 1}, {0, 0, 1, 0}, {0, 0, 0, 1}}},
+		{kf.SetMeasurementMatrix, [][]float32{{1, 0, 0, 0}, {0, 1, 0, 0}}},
+		{kf.SetProcessNoiseCov, [][]float32{{0.1, 0, 0, 0}, {0, 0.1, 0, 0}, {0, 0, 1, 0}, {0, 0, 0, 1}}},
+		{kf.SetMeasurementNoiseCov, [][]float32{{4, 0}, {0, 4}}},
+		{kf.SetErrorCovPost, [][]float32{{1, 0, 0, 0}, {0, 1, 0, 0}, {0, 0, 100, 0}, {0, 0, 0, 100}}},
+		{kf.SetStatePost, [][]float32{{float32(start.X)}, {float32(start.Y)}, {0}, {0}}},
+	} {
+		mat := float32Mat(m.rows)
+		m.set(mat)
+		mat.Close()
+	}
+	return &pointFilter{kf: kf, measurement: gocv.NewMatWithSize(2, 1, gocv.MatTypeCV32F)}
+}
+
+func (f *pointFilter) Predict() image.Point {
+	state := f.kf.Predict()
+	defer state.Close()
+	return image.Pt(int(state.GetFloatAt(0, 0)), int(state.GetFloatAt(1, 0)))
+}
+
+func (f *pointFilter) Correct(pt image.Point) image.Point {
+	f.measurement.SetFloatAt(0, 0, float32(pt.X))
+	f.measurement.SetFloatAt(1, 0, float32(pt.Y))
+	state := f.kf.Correct(f.measurement)
+	defer state.Close()
+	return image.Pt(int(state.GetFloatAt(0, 0)), int(state.GetFloatAt(1, 0)))
+}
+
+func (f *pointFilter) Close() {
+	f.kf.Close()
+	f.measurement.Close()
+}
+
+func float32Mat(rows [][]float32) gocv.Mat {
+	m := gocv.NewMatWithSize(len(rows), len(rows[0]), gocv.MatTypeCV32F)
+	for r, row := range rows {
+		for c, v := range row {
+			m.SetFloatAt(r, c, v)
+		}
+	}
+	return m
+}
+
+func (c *ClubAnalyzer) Close() error {
+	return nil
+}
+
+// ClubOverlay draws the club head path up to the frame being shown, coloured by swing segment.
+type ClubOverlay struct {
+	results *clipResults[ClubTrack]
+}
+
+func NewClubOverlay(analysis *Analysis, camera string) *ClubOverlay {
+	return &ClubOverlay{results: newClipResults[ClubTrack](analysis, camera, ClubAnalysis)}
+}
+
+func (o *ClubOverlay) Draw(img *gocv.Mat, frame PlaybackFrame) {
+	if frame.Live {
+		return
+	}
+	track := o.results.get(frame.Shot)
+	if track == nil || len(track.Points) == 0 {
+		return
+	}
+
+	top := track.Top(frame.Impact)
+	var head *ClubPoint
+	for i := 1; i < len(track.Points); i++ {
+		p := track.Points[i]
+		if p.Frame > frame.Index {
+			break
+		}
+		c := clubFollowColor
+		switch {
+		case i <= top:
+			c = clubBackswingColor
+		case p.Frame <= frame.Impact:
+			c = clubDownswingColor
+		}
+		gocv.Line(img, track.Points[i-1].Point(), p.Point(), c, 3)
+		head = &track.Points[i]
+	}
+	if head != nil {
+		gocv.Circle(img, head.Point(), 8, clubDownswingColor, 2)
+	}
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestClubTrackTop(t *testing.T) {
+	tests := []struct {
+		name   string
+		points []ClubPoint
+		impact int
+		want   int
+	}{
+		{name: "no points", impact: 10, want: -1},
+		{name: "only after impact", points: []ClubPoint{{Frame: 12, Y: 50}, {Frame: 13, Y: 40}}, impact: 10, want: -1},
+		{
+			name:   "highest before impact",
+			points: []ClubPoint{{Frame: 0, Y: 300}, {Frame: 3, Y: 120}, {Frame: 6, Y: 80}, {Frame: 8, Y: 200}, {Frame: 10, Y: 310}},
+			impact: 10,
+			want:   2,
+		},
+		{
+			name:   "ignores the finish above the top",
+			points: []ClubPoint{{Frame: 2, Y: 150}, {Frame: 5, Y: 100}, {Frame: 10, Y: 300}, {Frame: 15, Y: 20}},
+			impact: 10,
+			want:   1,
+		},
+		{
+			name:   "first of equal heights",
+			points: []ClubPoint{{Frame: 2, Y: 100}, {Frame: 3, Y: 100}, {Frame: 6, Y: 250}},
+			impact: 6,
+			want:   0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			track := &ClubTrack{Points: tt.points}
+			if got := track.Top(tt.impact); got != tt.want {
+				t.Errorf("Top(%d) = %d, want %d", tt.impact, got, tt.want)
+			}
+		})
+	}
+}
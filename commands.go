@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"gocv.io/x/gocv"
+)
+
+// Command is a command name, optionally followed by an argument, e.g. "speed 0.25" or "tag good".
+type Command string
+
+func (c Command) split() (name, arg string) {
+	name, arg, _ = strings.Cut(string(c), " ")
+	return name, arg
+}
+
+// CommandInfo describes a command for validating key bindings and the help overlay.
+type CommandInfo struct {
+	Name        string
+	Description string
+	// the command needs an argument, e.g. the speed or tag
+	Argument bool
+}
+
+// Commands lists every command that can be bound to a key, in the order the help overlay shows them.
+var Commands = []CommandInfo{
+	{Name: "quit", Description: "quit"},
+	{Name: "help", Description: "show or hide this help"},
+	{Name: "save", Description: "save a shot now"},
+	{Name: "live", Description: "switch between live view and replay"},
+	{Name: "fullscreen", Description: "toggle fullscreen"},
+	{Name: "pause", Description: "pause or resume"},
+	{Name: "step_forward", Description: "step a frame forward"},
+	{Name: "step_backward", Description: "step a frame back"},
+	{Name: "speed_up", Description: "play faster"},
+	{Name: "speed_down", Description: "play slower"},
+	{Name: "speed", Description: "play at speed", Argument: true},
+	{Name: "ramp", Description: "toggle the speed ramp"},
+	{Name: "impact", Description: "jump to impact"},
+	{Name: "restart", Description: "restart the replay"},
+	{Name: "reference", Description: "set the replay as the reference swing"},
+	{Name: "compare", Description: "cycle the compare mode"},
+	{Name: "reference_forward", Description: "nudge the reference forward"},
+	{Name: "reference_backward", Description: "nudge the reference back"},
+	{Name: "next_shot", Description: "next shot"},
+	{Name: "previous_shot", Description: "previous shot"},
+	{Name: "shot_list", Description: "show or hide the shot list"},
+	{Name: "tag", Description: "tag or untag the shot", Argument: true},
+	{Name: "delete_last_shot", Description: "delete the shot being replayed"},
+	{Name: "draw", Description: "drawing tool", Argument: true},
+	{Name: "clear_annotations", Description: "clear the drawings"},
+	{Name: "info", Description: "show or hide the shot info"},
+	{Name: "seed_club", Description: "click the club head to track it"},
+}
+
+// KeyBindings maps commands to the keys that run them.
+// Keys are single characters or one of the names in keyNames.
+type KeyBindings map[Command][]string
+
+var keyNames = map[string]int{
+	"space":     ' ',
+	"esc":       27,
+	"enter":     13,
+	"tab":       9,
+	"backspace": 8,
+}
+
+func DefaultKeyBindings() KeyBindings {
+	return KeyBindings{
+		"quit":               {"q", "esc"},
+		"help":               {"h", "?"},
+		"save":               {"enter"},
+		"live":               {"v"},
+		"fullscreen":         {"f"},
+		"pause":              {"space"},
+		"step_forward":       {"."},
+		"step_backward":      {","},
+		"speed_up":           {"=", "+"},
+		"speed_down":         {"-"},
+		"speed 0.05":         {"5"},
+		"speed 0.1":          {"6"},
+		"speed 0.25":         {"7"},
+		"speed 0.5":          {"8"},
+		"speed 1":            {"9"},
+		"speed 2":            {"0"},
+		"ramp":               {"s"},
+		"impact":             {"i"},
+		"restart":            {"r"},
+		"reference":          {"b"},
+		"compare":            {"c"},
+		"reference_forward":  {"]"},
+		"reference_backward": {"["},
+		"next_shot":          {"n"},
+		"previous_shot":      {"p"},
+		"shot_list":          {"l"},
+		"tag good":           {"g"},
+		"tag bad":            {"t"},
+		"delete_last_shot":   {"d"},
+		"draw line":          {"1"},
+		"draw angle":         {"2"},
+		"draw circle":        {"3"},
+		"draw rectangle":     {"4"},
+		"clear_annotations":  {"x"},
+		"info":               {"o"},
+		"seed_club":          {"k"},
+	}
+}
+
+func parseKey(key string) (int, error) {
+	if code, ok := keyNames[strings.ToLower(key)]; ok {
+		return code, nil
+	}
+	if r, size := utf8.DecodeRuneInString(key); size == len(key) && r < utf8.RuneSelf {
+		return int(r), nil
+	}
+	return 0, fmt.Errorf("invalid key %q", key)
+}
+
+// CommandDispatcher runs the command bound to each key pressed in the windows.
+// Handlers run on the main thread, so they must hand slow work to another goroutine to keep the windows drawing.
+type CommandDispatcher struct {
+	bindings KeyBindings
+	keys     map[int]Command
+	handlers map[string]func(arg string)
+	showHelp bool
+}
+
+// NewCommandDispatcher checks the key bindings, every key can only run one command.
+func NewCommandDispatcher(bindings KeyBindings) (*CommandDispatcher, error) {
+	d := &CommandDispatcher{
+		bindings: bindings,
+		keys:     make(map[int]Command),
+		handlers: make(map[string]func(arg string)),
+	}
+	for command, keys := range bindings {
+		name, arg := command.split()
+		i := slices.IndexFunc(Commands, func(c CommandInfo) bool { return c.Name == name })
+		if i < 0 {
+			return nil, fmt.Errorf("unknown command %q", command)
+		}
+		if Commands[i].Argument && arg == "" {
+			return nil, fmt.Errorf("command %q needs an argument", command)
+		}
+		if !Commands[i].Argument && arg != "" {
+			return nil, fmt.Errorf("command %q takes no argument", command)
+		}
+		for _, key := range keys {
+			code, err := parseKey(key)
+			if err != nil {
+				return nil, fmt.Errorf("command %q: %w", command, err)
+			}
+			if other, ok := d.keys[code]; ok {
+				return nil, fmt.Errorf("key %q is bound to both %q and %q", key, other, command)
+			}
+			d.keys[code] = command
+		}
+	}
+	d.Handle("help", func(string) { d.showHelp = !d.showHelp })
+	return d, nil
+}
+
+// Handle sets the function that runs the named command, it gets the command's argument.
+func (d *CommandDispatcher) Handle(name string, fn func(arg string)) {
+	d.handlers[name] = fn
+}
+
+// HandleKey runs the command bound to the key, if any.
+func (d *CommandDispatcher) HandleKey(key int) {
+	command, ok := d.keys[key]
+	if !ok {
+		return
+	}
+	name, arg := command.split()
+	fn, ok := d.handlers[name]
+	if !ok {
+		fmt.Printf("command %q is not available\n", command)
+		return
+	}
+	fn(arg)
+}
+
+// Draw lists the key bindings while the help is shown.
+func (d *CommandDispatcher) Draw(img *gocv.Mat, frame PlaybackFrame) {
+	if !d.showHelp {
+		return
+	}
+	var lines []string
+	for _, info := range Commands {
+		var commands []Command
+		for command := range d.bindings {
+			if name, _ := command.split(); name == info.Name && len(d.bindings[command]) > 0 {
+				commands = append(commands, command)
+			}
+		}
+		slices.Sort(commands)
+		for _, command := range commands {
+			_, arg := command.split()
+			description := info.Description
+			if arg != "" {
+				description += " " + arg
+			}
+			lines = append(lines, fmt.Sprintf("%-12s %s", strings.Join(d.bindings[command], " "), description))
+		}
+	}
+
+	const lineHeight = 18
+	left := max(0, img.Cols()-460)
+	gocv.Rectangle(img, image.Rect(left, 0, img.Cols(), len(lines)*lineHeight+16), color.RGBA{A: 255}, -1)
+	for i, line := range lines {
+		gocv.PutText(img, line, image.Pt(left+10, (i+1)*lineHeight), gocv.FontHersheySimplex, 0.45, color.RGBA{R: 255, G: 255, B: 255, A: 255}, 1)
+	}
+}
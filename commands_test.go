@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestNewCommandDispatcher(t *testing.T) {
+	tests := []struct {
+		name     string
+		bindings KeyBindings
+		err      bool
+		// a key that must run the command with the argument
+		key int
+		run string
+		arg string
+	}{
+		{name: "defaults", bindings: DefaultKeyBindings(), key: '7', run: "speed", arg: "0.25"},
+		{name: "no bindings", bindings: KeyBindings{}},
+		{name: "named key in any case", bindings: KeyBindings{"pause": {"Space"}}, key: ' ', run: "pause"},
+		{name: "several keys", bindings: KeyBindings{"quit": {"q", "esc"}}, key: 27, run: "quit"},
+		{name: "argument", bindings: KeyBindings{"tag good": {"g"}}, key: 'g', run: "tag", arg: "good"},
+		{name: "key bound twice", bindings: KeyBindings{"pause": {"p"}, "previous_shot": {"p"}}, err: true},
+		{name: "unknown command", bindings: KeyBindings{"rewind": {"w"}}, err: true},
+		{name: "missing argument", bindings: KeyBindings{"speed": {"9"}}, err: true},
+		{name: "unexpected argument", bindings: KeyBindings{"pause now": {"p"}}, err: true},
+		{name: "empty key", bindings: KeyBindings{"pause": {""}}, err: true},
+		{name: "several characters", bindings: KeyBindings{"pause": {"ctrl+p"}}, err: true},
+		{name: "non ascii key", bindings: KeyBindings{"pause": {"é"}}, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d, err := NewCommandDispatcher(tt.bindings)
+			if tt.err {
+				if err == nil {
+					t.Fatal("NewCommandDispatcher succeeded, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("NewCommandDispatcher: %v", err)
+			}
+			if tt.run == "" {
+				return
+			}
+			var ran bool
+			d.Handle(tt.run, func(arg string) {
+				ran = true
+				if arg != tt.arg {
+					t.Errorf("%s ran with %q, want %q", tt.run, arg, tt.arg)
+				}
+			})
+			d.HandleKey(tt.key)
+			if !ran {
+				t.Errorf("key %q didn't run %s", rune(tt.key), tt.run)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"image"
+
+	"gocv.io/x/gocv"
+)
+
+const (
+	// opacity of the reference swing in the overlay compare mode
+	DefaultCompareAlpha = 0.5
+)
+
+// CompareMode is how a replay is shown together with the reference swing.
+type CompareMode int
+
+const (
+	CompareOff CompareMode = iota
+	CompareSideBySide
+	CompareOverlay
+)
+
+func (m CompareMode) String() string {
+	switch m {
+	case CompareSideBySide:
+		return "side by side"
+	case CompareOverlay:
+		return "overlay"
+	default:
+		return "off"
+	}
+}
+
+// composeCompare combines a replay frame with the reference frame into dst.
+func composeCompare(mode CompareMode, frame, ref gocv.Mat, alpha float64, dst *gocv.Mat) {
+	// the reference is scaled to the frame's size so both can be combined
+	scaled := gocv.NewMat()
+	defer scaled.Close()
+	gocv.Resize(ref, &scaled, image.Pt(frame.Cols(), frame.Rows()), 0, 0, gocv.InterpolationLinear)
+
+	switch mode {
+	case CompareSideBySide:
+		gocv.Hconcat(frame, scaled, dst)
+	case CompareOverlay:
+		gocv.AddWeighted(frame, 1-alpha, scaled, alpha, 0, dst)
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	// directory that saved shots are written to
+	DefaultVideosDir = "videos"
+)
+
+// Config holds all the settings in effect for a session.
+type Config struct {
+	VideosDir string `json:"videos_dir"`
+	// club being hit this session, recorded with every shot
+	Club      string          `json:"club"`
+	Audio     AudioConfig     `json:"audio"`
+	Capture   CaptureConfig   `json:"capture"`
+	Cameras   []CameraConfig  `json:"cameras"`
+	Playback  PlaybackConfig  `json:"playback"`
+	Retention RetentionConfig `json:"retention"`
+	Web       WebConfig       `json:"web"`
+	// text and progress bar drawn over the replays
+	Info InfoOverlayConfig `json:"info"`
+	// placement of the playback windows
+	Layout LayoutConfig `json:"layout"`
+	// keys of the commands, bindings in the config file replace the default keys of their command
+	Keys KeyBindings `json:"keys"`
+	// analyses run on every saved shot
+	Analysis AnalysisConfig `json:"analysis"`
+	// lens calibration of the cameras and where frames are undistorted
+	Calibration CalibrationConfig `json:"calibration"`
+	// how often capture health metrics are logged, 0 disables the log
+	StatsInterval Duration `json:"stats_interval"`
+	// capture and save shots without creating any windows
+	Headless bool `json:"headless"`
+}
+
+type AudioConfig struct {
+	DecibelThreshold     float64  `json:"decibel_threshold"`
+	MinDetectionInterval Duration `json:"min_detection_interval"`
+}
+
+type CaptureConfig struct {
+	FPS             float64 `json:"fps"`
+	Width           int     `json:"width"`
+	Height          int     `json:"height"`
+	SecondsToRecord int     `json:"seconds_to_record"`
+	// duration of video to capture after event, the rest of the recording is pre-roll
+	DurationAfterEvent Duration `json:"duration_after_event"`
+}
+
+// CameraConfig selects a camera device and its controls.
+// Controls that are not set are left at the driver's default, their values are driver specific.
+type CameraConfig struct {
+	Name   string `json:"name"`
+	Device int    `json:"device"`
+	// e.g. V4L2: 1 is manual and 3 is auto, DirectShow: 0.25 is manual and 0.75 is auto
+	AutoExposure *float64 `json:"auto_exposure,omitempty"`
+	// exposure is only applied with auto exposure off, e.g. V4L2 in units of 100µs, DirectShow as log2 seconds
+	Exposure         *float64 `json:"exposure,omitempty"`
+	Gain             *float64 `json:"gain,omitempty"`
+	AutoFocus        *bool    `json:"auto_focus,omitempty"`
+	Focus            *float64 `json:"focus,omitempty"`
+	AutoWhiteBalance *bool    `json:"auto_white_balance,omitempty"`
+	// white balance colour temperature in kelvin
+	WhiteBalance *float64 `json:"white_balance,omitempty"`
+}
+
+type PlaybackConfig struct {
+	Speed float64 `json:"speed"`
+	// id of a saved shot to compare replays with
+	ReferenceShot string `json:"reference_shot"`
+	// opacity of the reference swing in the overlay compare mode
+	CompareAlpha float64 `json:"compare_alpha"`
+	// variable speed around impact, used instead of the fixed speed when enabled
+	Ramp SpeedRampConfig `json:"ramp"`
+	// number of times a replay loops before switching back to the live view, 0 keeps looping
+	LiveAfterLoops int `json:"live_after_loops"`
+}
+
+// RetentionConfig limits how much video is kept on disk, a zero value means no limit.
+// Shots are only ever deleted when a limit is set in the config.
+type RetentionConfig struct {
+	MaxTotalBytes int64    `json:"max_total_bytes"`
+	MaxAge        Duration `json:"max_age"`
+	MaxShots      int      `json:"max_shots"`
+}
+
+// WebConfig enables the built-in web ui for reviewing shots from a browser.
+type WebConfig struct {
+	// address to listen on, e.g. ":8080" to allow tablets on the local network, empty disables the web ui
+	Addr string `json:"addr"`
+}
+
+func DefaultConfig() Config {
+	return Config{
+		VideosDir: DefaultVideosDir,
+		Audio: AudioConfig{
+			DecibelThreshold:     DefaultClubStrikeDecibelThreshold,
+			MinDetectionInterval: Duration(DefaultMinDetectionInterval),
+		},
+		Capture: CaptureConfig{
+			FPS:                DefaultFPS,
+			Width:              DefaultCamWidth,
+			Height:             DefaultCamHeight,
+			SecondsToRecord:    DefaultSecondsToRecord,
+			DurationAfterEvent: Duration(DefaultDurationToCaptureAfterEvent),
+		},
+		Cameras: []CameraConfig{
+			{Name: "front", Device: 0},
+			{Name: "back", Device: 1},
+		},
+		Playback: PlaybackConfig{
+			Speed:          DefaultPlaybackSpeed,
+			CompareAlpha:   DefaultCompareAlpha,
+			Ramp:           DefaultSpeedRampConfig(),
+			LiveAfterLoops: DefaultLiveAfterLoops,
+		},
+		Info: DefaultInfoOverlayConfig(),
+		Keys: DefaultKeyBindings(),
+		Analysis: AnalysisConfig{
+			Pose: DefaultPoseConfig(),
+			Club: DefaultClubConfig(),
+			Ball: DefaultBallConfig(),
+		},
+		Calibration:   DefaultCalibrationConfig(),
+		StatsInterval: Duration(DefaultStatsInterval),
+	}
+}
+
+// LoadConfig reads a json config file, settings missing from the file keep their defaults.
+func LoadConfig(file string) (Config, error) {
+	cfg := DefaultConfig()
+	b, err := os.ReadFile(file)
+	if err != nil {
+		return cfg, fmt.Errorf("error reading config: %w", err)
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		return cfg, fmt.Errorf("error parsing config %s: %w", file, err)
+	}
+	names := make(map[string]bool)
+	for i, camera := range cfg.Cameras {
+		if camera.Name == "" {
+			return cfg, fmt.Errorf("camera %d in config %s has no name", i, file)
+		}
+		// windows, clips and calibrations are looked up by camera name
+		if names[camera.Name] {
+			return cfg, fmt.Errorf("camera name %q is used twice in config %s", camera.Name, file)
+		}
+		names[camera.Name] = true
+	}
+	switch cfg.Calibration.Undistort {
+	case "", UndistortCapture, UndistortPlayback:
+	default:
+		return cfg, fmt.Errorf("invalid undistort %q in config %s, expected %q or %q", cfg.Calibration.Undistort, file, UndistortCapture, UndistortPlayback)
+	}
+	if _, err := NewCommandDispatcher(cfg.Keys); err != nil {
+		return cfg, fmt.Errorf("invalid keys in config %s: %w", file, err)
+	}
+	return cfg, nil
+}
+
+// Duration is a time.Duration that is written to json as a readable string, e.g. "2s".
+type Duration time.Duration
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var v any
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+	switch value := v.(type) {
+	case float64:
+		*d = Duration(value)
+	case string:
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q: %w", value, err)
+		}
+		*d = Duration(parsed)
+	default:
+		return fmt.Errorf("invalid duration %s", b)
+	}
+	return nil
+}
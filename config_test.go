@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestDurationJSON(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+		want Duration
+		out  string
+		err  bool
+	}{
+		{name: "seconds", in: `"2s"`, want: Duration(2 * time.Second), out: `"2s"`},
+		{name: "minutes and seconds", in: `"1m30s"`, want: Duration(90 * time.Second), out: `"1m30s"`},
+		{name: "milliseconds", in: `"150ms"`, want: Duration(150 * time.Millisecond), out: `"150ms"`},
+		{name: "zero", in: `"0s"`, want: 0, out: `"0s"`},
+		{name: "nanoseconds as a number", in: `1500000000`, want: Duration(1500 * time.Millisecond), out: `"1.5s"`},
+		{name: "invalid string", in: `"soon"`, err: true},
+		{name: "invalid type", in: `true`, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var d Duration
+			err := json.Unmarshal([]byte(tt.in), &d)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("Unmarshal(%s) = %s, want an error", tt.in, d)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unmarshal(%s): %v", tt.in, err)
+			}
+			if d != tt.want {
+				t.Errorf("Unmarshal(%s) = %s, want %s", tt.in, d, tt.want)
+			}
+			b, err := json.Marshal(d)
+			if err != nil {
+				t.Fatalf("Marshal(%s): %v", d, err)
+			}
+			if string(b) != tt.out {
+				t.Errorf("Marshal(%s) = %s, want %s", d, b, tt.out)
+			}
+			var back Duration
+			if err := json.Unmarshal(b, &back); err != nil || back != d {
+				t.Errorf("round trip of %s = %s, %v", d, back, err)
+			}
+		})
+	}
+}
+
+func TestLoadConfigUndistort(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		want   string
+		err    bool
+	}{
+		{name: "not set", config: `{}`, want: ""},
+		{name: "capture", config: `{"calibration": {"undistort": "capture"}}`, want: UndistortCapture},
+		{name: "playback", config: `{"calibration": {"undistort": "playback"}}`, want: UndistortPlayback},
+		{name: "unknown", config: `{"calibration": {"undistort": "always"}}`, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "config.json")
+			if err := os.WriteFile(file, []byte(tt.config), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			cfg, err := LoadConfig(file)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("LoadConfig(%s) succeeded, want an error", tt.config)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadConfig(%s): %v", tt.config, err)
+			}
+			if cfg.Calibration.Undistort != tt.want {
+				t.Errorf("undistort = %q, want %q", cfg.Calibration.Undistort, tt.want)
+			}
+			// the rest of the calibration keeps its defaults
+			if cfg.Calibration.Dir != DefaultCalibrationDir {
+				t.Errorf("calibration dir = %q, want %q", cfg.Calibration.Dir, DefaultCalibrationDir)
+			}
+		})
+	}
+}
+
+func TestLoadConfigCameras(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		want   int
+		err    bool
+	}{
+		{name: "two cameras", config: `{"cameras": [{"name": "front", "device": 0}, {"name": "side", "device": 1}]}`, want: 2},
+		// the first two cameras take their names from the defaults when they have none
+		{name: "no name", config: `{"cameras": [{"name": "front", "device": 0}, {"name": "side", "device": 1}, {"device": 2}]}`, err: true},
+		{name: "same name twice", config: `{"cameras": [{"name": "front", "device": 0}, {"name": "front", "device": 1}]}`, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "config.json")
+			if err := os.WriteFile(file, []byte(tt.config), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			cfg, err := LoadConfig(file)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("LoadConfig(%s) succeeded, want an error", tt.config)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadConfig(%s): %v", tt.config, err)
+			}
+			if len(cfg.Cameras) != tt.want {
+				t.Errorf("%d cameras, want %d", len(cfg.Cameras), tt.want)
+			}
+		})
+	}
+}
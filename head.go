@@ -0,0 +1,249 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+const (
+	// name of the head tracking analysis and its results files
+	HeadAnalysis = "head"
+	// faces found by the YuNet model with a lower score are ignored
+	DefaultHeadMinScore = 0.6
+	// without the tempo analysis the address is taken this long before impact, a little before most takeaways
+	DefaultHeadAddressBeforeImpact = 1500 * time.Millisecond
+)
+
+var (
+	headStartColor = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	headColor      = color.RGBA{R: 255, G: 200, A: 255}
+)
+
+// HeadConfig enables tracking the golfer's head with a face detection model stored locally.
+type HeadConfig struct {
+	// haar cascade (.xml) or YuNet model (.onnx), empty disables head tracking
+	Model string `json:"model"`
+	// face on camera to track the head on, empty tracks it on every camera
+	Camera string `json:"camera"`
+	// scale at the golfer's distance from the camera, e.g. measured from an object of known size;
+	// 0 reports the movement in pixels
+	CentimetresPerPixel float64 `json:"cm_per_pixel"`
+}
+
+// HeadTrack is the head's box in every frame of a clip, a zero box means the head wasn't found.
+type HeadTrack struct {
+	Frames []Region `json:"frames"`
+}
+
+// HeadSway is how far the head moved from address to impact.
+type HeadSway struct {
+	// frame the head's starting position was taken from
+	AddressFrame int `json:"address_frame"`
+	// right in the frame and up are positive
+	Lateral  float64 `json:"lateral"`
+	Vertical float64 `json:"vertical"`
+	// "cm" when the camera is calibrated, otherwise "px"
+	Unit string `json:"unit"`
+}
+
+// faceDetector finds the largest or most confident face in a frame.
+type faceDetector interface {
+	detect(frame gocv.Mat) (image.Rectangle, bool)
+	Close() error
+}
+
+// HeadAnalyzer finds the head in every frame with face detection, following it with a tracker while the face
+// isn't detected, e.g. when the golfer turns away.
+type HeadAnalyzer struct {
+	cfg      HeadConfig
+	detector faceDetector
+}
+
+func NewHeadAnalyzer(cfg HeadConfig) (*HeadAnalyzer, error) {
+	if _, err := os.Stat(cfg.Model); err != nil {
+		return nil, fmt.Errorf("error loading face model: %w", err)
+	}
+	var detector faceDetector
+	switch strings.ToLower(filepath.Ext(cfg.Model)) {
+	case ".xml":
+		cascade := gocv.NewCascadeClassifier()
+		if !cascade.Load(cfg.Model) {
+			cascade.Close()
+			return nil, fmt.Errorf("error loading face model %s", cfg.Model)
+		}
+		detector = cascadeFaces{cascade}
+	case ".onnx":
+		detector = &yunetFaces{detector: gocv.NewFaceDetectorYN(cfg.Model, "", image.Pt(320, 320))}
+	default:
+		return nil, fmt.Errorf("unknown face model %s, expected a haar cascade (.xml) or YuNet model (.onnx)", cfg.Model)
+	}
+	return &HeadAnalyzer{cfg: cfg, detector: detector}, nil
+}
+
+func (h *HeadAnalyzer) Name() string {
+	return HeadAnalysis
+}
+
+// Analyze saves the head track and records the sway in info, measured from the address the tempo analysis found,
+// or from a frame shortly before the swing started without it.
+func (h *HeadAnalyzer) Analyze(shot *Shot, clip *Clip, info *ShotClip) error {
+	if h.cfg.Camera != "" && clip.Camera != h.cfg.Camera {
+		return nil
+	}
+	impact := clip.ImpactFrame
+	if impact < 0 || impact >= clip.Len() {
+		return fmt.Errorf("impact frame %d is not in the clip", impact)
+	}
+
+	tracker := gocv.NewTrackerMIL()
+	defer tracker.Close()
+	track := HeadTrack{Frames: make([]Region, clip.Len())}
+	var last image.Rectangle
+	var tracking bool
+	for i := 0; i < clip.Len(); i++ {
+		frame := clip.Frame(i)
+		box, ok := h.detector.detect(frame)
+		switch {
+		case ok:
+			tracking = false
+		case !last.Empty():
+			// start the tracker on the last frame the head was found in
+			if !tracking {
+				tracking = tracker.Init(clip.Frame(i-1), last)
+			}
+			if tracking {
+				box, ok = tracker.Update(frame)
+			}
+		}
+		if !ok {
+			last = image.Rectangle{}
+			continue
+		}
+		last = box
+		track.Frames[i] = Region{X: box.Min.X, Y: box.Min.Y, Width: box.Dx(), Height: box.Dy()}
+	}
+	if err := saveClipData(shot, clip.Camera, HeadAnalysis, track); err != nil {
+		return err
+	}
+
+	address := max(0, impact-int(clip.FPS*DefaultHeadAddressBeforeImpact.Seconds()))
+	if info.Tempo != nil {
+		address = min(max(info.Tempo.Address, 0), impact)
+	}
+	start, end := track.Frames[address], track.Frames[impact]
+	if start.Width == 0 || end.Width == 0 {
+		return fmt.Errorf("head not found at address and impact")
+	}
+	d := end.Rect().Min.Add(end.Rect().Size().Div(2)).Sub(start.Rect().Min.Add(start.Rect().Size().Div(2)))
+	sway := HeadSway{AddressFrame: address, Lateral: float64(d.X), Vertical: float64(-d.Y), Unit: "px"}
+	if h.cfg.CentimetresPerPixel > 0 {
+		sway.Lateral *= h.cfg.CentimetresPerPixel
+		sway.Vertical *= h.cfg.CentimetresPerPixel
+		sway.Unit = "cm"
+	}
+	info.Head = &sway
+	return nil
+}
+
+func (h *HeadAnalyzer) Close() error {
+	return h.detector.Close()
+}
+
+type cascadeFaces struct {
+	cascade gocv.CascadeClassifier
+}
+
+func (c cascadeFaces) detect(frame gocv.Mat) (image.Rectangle, bool) {
+	gray := grayFrame(frame)
+	defer gray.Close()
+	var largest image.Rectangle
+	for _, face := range c.cascade.DetectMultiScale(gray) {
+		if face.Dx()*face.Dy() > largest.Dx()*largest.Dy() {
+			largest = face
+		}
+	}
+	return largest, !largest.Empty()
+}
+
+func (c cascadeFaces) Close() error {
+	return c.cascade.Close()
+}
+
+type yunetFaces struct {
+	detector gocv.FaceDetectorYN
+	size     image.Point
+}
+
+func (y *yunetFaces) detect(frame gocv.Mat) (image.Rectangle, bool) {
+	if size := image.Pt(frame.Cols(), frame.Rows()); size != y.size {
+		y.detector.SetInputSize(size)
+		y.size = size
+	}
+	faces := gocv.NewMat()
+	defer faces.Close()
+	y.detector.Detect(frame, &faces)
+
+	// each row is the face box, five landmarks and the score
+	var best image.Rectangle
+	var bestScore float32 = DefaultHeadMinScore
+	for i := 0; i < faces.Rows(); i++ {
+		if score := faces.GetFloatAt(i, 14); score >= bestScore {
+			left, top := int(faces.GetFloatAt(i, 0)), int(faces.GetFloatAt(i, 1))
+			width, height := int(faces.GetFloatAt(i, 2)), int(faces.GetFloatAt(i, 3))
+			best, bestScore = image.Rect(left, top, left+width, top+height), score
+		}
+	}
+	return best, !best.Empty()
+}
+
+func (y *yunetFaces) Close() error {
+	y.detector.Close()
+	return nil
+}
+
+// HeadOverlay draws the head's box at address, its current box and how far it moved.
+type HeadOverlay struct {
+	track *clipResults[HeadTrack]
+	info  *clipResults[ShotClip]
+}
+
+func NewHeadOverlay(analysis *Analysis, camera string) *HeadOverlay {
+	return &HeadOverlay{
+		track: newClipResults[HeadTrack](analysis, camera, HeadAnalysis),
+		info:  newClipInfoResults(analysis, camera, HeadAnalysis),
+	}
+}
+
+func (o *HeadOverlay) Draw(img *gocv.Mat, frame PlaybackFrame) {
+	if frame.Live {
+		return
+	}
+	track, info := o.track.get(frame.Shot), o.info.get(frame.Shot)
+	if track == nil || info == nil || info.Head == nil {
+		return
+	}
+	sway := info.Head
+	if sway.AddressFrame >= len(track.Frames) {
+		return
+	}
+	start := track.Frames[sway.AddressFrame].Rect()
+	gocv.Rectangle(img, start, headStartColor, 1)
+	if frame.Index < 0 || frame.Index >= len(track.Frames) || track.Frames[frame.Index].Width == 0 {
+		return
+	}
+	current := track.Frames[frame.Index].Rect()
+	gocv.Rectangle(img, current, headColor, 2)
+	center := func(r image.Rectangle) image.Point {
+		return r.Min.Add(r.Size().Div(2))
+	}
+	gocv.Line(img, center(start), center(current), headColor, 2)
+	text := fmt.Sprintf("head %+.1f %s lateral, %+.1f %s vertical", sway.Lateral, sway.Unit, sway.Vertical, sway.Unit)
+	gocv.PutText(img, text, image.Pt(start.Min.X, start.Min.Y-10), gocv.FontHersheySimplex, 0.6, headColor, 2)
+}
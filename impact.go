@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+const (
+	// name of the impact refinement analysis
+	ImpactAnalysis = "impact"
+	// the impact is searched for this far either side of the frame closest to the detected impact time
+	DefaultImpactSearchWindow = 150 * time.Millisecond
+	// mean change of the area around the ball when the club reaches it
+	DefaultImpactMinChange = 20
+)
+
+// ImpactConfig enables refining the impact frame of clips from the video,
+// which corrects for the latency between the sound and the picture of each camera.
+type ImpactConfig struct {
+	// area around the ball at address by camera name, clips of cameras without one keep the audio impact frame
+	Regions map[string]Region `json:"regions"`
+}
+
+// ImpactAnalyzer finds the impact frame near the one closest to the detected impact time, where the club reaches the ball.
+// It runs before the other analyzers so they use the refined impact frame.
+type ImpactAnalyzer struct {
+	cfg ImpactConfig
+}
+
+func NewImpactAnalyzer(cfg ImpactConfig) *ImpactAnalyzer {
+	return &ImpactAnalyzer{cfg: cfg}
+}
+
+func (a *ImpactAnalyzer) Name() string {
+	return ImpactAnalysis
+}
+
+func (a *ImpactAnalyzer) Analyze(shot *Shot, clip *Clip, info *ShotClip) error {
+	region, ok := a.cfg.Regions[clip.Camera]
+	if !ok {
+		return nil
+	}
+	if info.AudioImpactFrame == 0 {
+		info.AudioImpactFrame = info.ImpactFrame
+	}
+	expected := min(info.AudioImpactFrame, clip.Len()-1)
+	window := int(clip.FPS * DefaultImpactSearchWindow.Seconds())
+	lo, hi := max(1, expected-window), min(clip.Len()-1, expected+window)
+	if hi < lo {
+		return fmt.Errorf("clip is too short to find the impact")
+	}
+	frames := newGrayFrames(clip)
+	defer frames.Close()
+
+	// the first frame the club covers the ball or the ball is gone, compared to before the search
+	first := frames.Frame(lo - 1)
+	area := region.Rect().Intersect(image.Rect(0, 0, first.Cols(), first.Rows()))
+	impact := -1
+	for i := lo; i <= hi; i++ {
+		if frameChange(first, frames.Frame(i), area) >= DefaultImpactMinChange {
+			impact = i
+			break
+		}
+	}
+	if impact < 0 {
+		return fmt.Errorf("no change around the ball near frame %d", expected)
+	}
+
+	if impact != info.ImpactFrame {
+		fmt.Printf("impact of %s clip of shot %s moved from frame %d to %d\n", clip.Camera, shot.ID, info.ImpactFrame, impact)
+	}
+	info.ImpactFrame = impact
+	clip.ImpactFrame = impact
+	return nil
+}
+
+// frameChange is the mean difference of an area between two gray frames.
+func frameChange(a, b gocv.Mat, area image.Rectangle) float64 {
+	if area.Empty() {
+		return 0
+	}
+	ra, rb := a.Region(area), b.Region(area)
+	defer ra.Close()
+	defer rb.Close()
+	diff := gocv.NewMat()
+	defer diff.Close()
+	gocv.AbsDiff(ra, rb, &diff)
+	return diff.Mean().Val1
+}
+
+func (a *ImpactAnalyzer) Close() error {
+	return nil
+}
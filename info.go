@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"strings"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+const (
+	// height of the progress bar along the bottom of the frame
+	infoProgressSize = 8
+)
+
+var (
+	infoTextColor   = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+	infoShadowColor = color.RGBA{A: 255}
+	infoBarColor    = color.RGBA{R: 80, G: 80, B: 80, A: 255}
+	infoPlayedColor = color.RGBA{R: 0, G: 200, B: 255, A: 255}
+	infoImpactColor = color.RGBA{R: 255, A: 255}
+	infoLiveColor   = color.RGBA{R: 255, A: 255}
+)
+
+// InfoOverlayConfig selects what the information overlay shows on the replays.
+type InfoOverlayConfig struct {
+	Enabled    bool `json:"enabled"`
+	ShotNumber bool `json:"shot_number"`
+	Time       bool `json:"time"`
+	// detection level, or its source for detections without one
+	Level    bool    `json:"level"`
+	Speed    bool    `json:"speed"`
+	Frame    bool    `json:"frame"`
+	Progress bool    `json:"progress"`
+	Scale    float64 `json:"scale"`
+}
+
+func DefaultInfoOverlayConfig() InfoOverlayConfig {
+	return InfoOverlayConfig{
+		Enabled:    true,
+		ShotNumber: true,
+		Time:       true,
+		Level:      true,
+		Speed:      true,
+		Frame:      true,
+		Progress:   true,
+		Scale:      0.6,
+	}
+}
+
+// InfoOverlay shows which shot is replaying, how it was detected, the playback speed and the position in the clip.
+// It's drawn on the main thread, so it needs no locking.
+type InfoOverlay struct {
+	cfg     InfoOverlayConfig
+	library *Library
+
+	// number of the shot being replayed, looked up once per shot
+	shotID string
+	number int
+}
+
+func NewInfoOverlay(cfg InfoOverlayConfig, library *Library) *InfoOverlay {
+	return &InfoOverlay{cfg: cfg, library: library}
+}
+
+// RegisterCommands shows or hides the overlay with the info command.
+func (o *InfoOverlay) RegisterCommands(d *CommandDispatcher) {
+	d.Handle("info", func(string) { o.cfg.Enabled = !o.cfg.Enabled })
+}
+
+func (o *InfoOverlay) Draw(img *gocv.Mat, frame PlaybackFrame) {
+	if !o.cfg.Enabled {
+		return
+	}
+	if frame.Live {
+		o.drawLines(img, []string{"LIVE"}, infoLiveColor)
+		return
+	}
+
+	var lines []string
+	if shot := frame.Shot; shot != nil {
+		var header []string
+		if o.cfg.ShotNumber {
+			header = append(header, fmt.Sprintf("shot #%d", o.shotNumber(shot)))
+		}
+		if o.cfg.Time {
+			header = append(header, shot.ImpactTime.Format("Jan 02 15:04:05"))
+		}
+		if o.cfg.Level {
+			header = append(header, shot.Trigger())
+		}
+		if len(header) > 0 {
+			lines = append(lines, strings.Join(header, "  "))
+		}
+	}
+	if o.cfg.Speed && frame.Speed > 0 {
+		lines = append(lines, fmt.Sprintf("speed %gx", math.Round(frame.Speed*100)/100))
+	}
+	if o.cfg.Frame && frame.Count > 0 {
+		line := fmt.Sprintf("frame %d/%d  impact %+d", frame.Index+1, frame.Count, frame.Index-frame.Impact)
+		if frame.FPS > 0 {
+			offset := time.Duration(float64(frame.Index-frame.Impact) * float64(time.Second) / frame.FPS)
+			line += fmt.Sprintf(" (%+.3fs)", offset.Seconds())
+		}
+		lines = append(lines, line)
+	}
+	o.drawLines(img, lines, infoTextColor)
+
+	if o.cfg.Progress && frame.Count > 1 {
+		o.drawProgress(img, frame)
+	}
+}
+
+// drawLines writes the lines in the bottom left corner, above the progress bar.
+func (o *InfoOverlay) drawLines(img *gocv.Mat, lines []string, c color.RGBA) {
+	lineHeight := int(45 * o.cfg.Scale)
+	y := img.Rows() - infoProgressSize - 10 - (len(lines)-1)*lineHeight
+	for _, line := range lines {
+		pt := image.Pt(10, y)
+		// a dark outline keeps the text readable on bright frames
+		gocv.PutText(img, line, pt, gocv.FontHersheySimplex, o.cfg.Scale, infoShadowColor, 4)
+		gocv.PutText(img, line, pt, gocv.FontHersheySimplex, o.cfg.Scale, c, 1)
+		y += lineHeight
+	}
+}
+
+// drawProgress draws the position in the clip along the bottom edge, with a marker on the impact frame.
+func (o *InfoOverlay) drawProgress(img *gocv.Mat, frame PlaybackFrame) {
+	width, bottom := img.Cols(), img.Rows()
+	top := bottom - infoProgressSize
+	x := func(idx int) int {
+		return idx * (width - 1) / (frame.Count - 1)
+	}
+	gocv.Rectangle(img, image.Rect(0, top, width, bottom), infoBarColor, -1)
+	gocv.Rectangle(img, image.Rect(0, top, x(frame.Index), bottom), infoPlayedColor, -1)
+	impact := x(frame.Impact)
+	gocv.Line(img, image.Pt(impact, top-6), image.Pt(impact, bottom), infoImpactColor, 3)
+}
+
+func (o *InfoOverlay) shotNumber(shot *Shot) int {
+	if shot.ID != o.shotID {
+		o.shotID = shot.ID
+		number, err := o.library.Number(shot)
+		if err != nil {
+			fmt.Printf("error numbering shot %s: %v\n", shot.ID, err)
+		}
+		o.number = number
+	}
+	return o.number
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"image"
+
+	"gocv.io/x/gocv"
+)
+
+const (
+	// layout key of the single window grid
+	GridWindowLayout = "grid"
+)
+
+// LayoutConfig places the playback windows on screen at startup.
+type LayoutConfig struct {
+	// position and size by camera name, or "grid" for the single window grid
+	Windows    map[string]WindowLayout `json:"windows"`
+	Fullscreen bool                    `json:"fullscreen"`
+	// show all cameras in one window instead of a window per camera
+	Grid bool `json:"grid"`
+	// columns of the grid, 0 puts all cameras side by side
+	GridColumns int `json:"grid_columns"`
+}
+
+// WindowLayout is a window's position and size in screen pixels, a zero width or height keeps the window's size.
+type WindowLayout struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// Screen is a top level window the playback is shown in, its methods run on the main thread.
+type Screen interface {
+	PlayNextFrame()
+	ApplyLayout(layout WindowLayout)
+	ToggleFullscreen()
+	Close() error
+}
+
+// placeScreen applies the configured layout of the named screen, windows without one are left where the os puts them.
+func placeScreen(screen Screen, cfg LayoutConfig, name string) {
+	if layout, ok := cfg.Windows[name]; ok {
+		screen.ApplyLayout(layout)
+	}
+	if cfg.Fullscreen {
+		screen.ToggleFullscreen()
+	}
+}
+
+func applyLayout(w *gocv.Window, layout WindowLayout) {
+	if layout.Width > 0 && layout.Height > 0 {
+		w.ResizeWindow(layout.Width, layout.Height)
+	}
+	w.MoveWindow(layout.X, layout.Y)
+}
+
+func setFullscreen(w *gocv.Window, fullscreen bool) {
+	if fullscreen {
+		w.SetWindowProperty(gocv.WindowPropertyFullscreen, gocv.WindowFullscreen)
+		return
+	}
+	w.SetWindowProperty(gocv.WindowPropertyFullscreen, gocv.WindowNormal)
+}
+
+// GridWindow composites the panels of all cameras into one window, e.g. to fill a tv.
+// Each panel is scaled to fit its cell, and mouse events are passed on to the panel under the mouse in frame coordinates.
+type GridWindow struct {
+	*gocv.Window
+	fullscreen bool
+	panels     []*VideoPlaybackWindow
+	// cell size and the area of the grid each panel was last drawn in
+	cell    image.Point
+	columns int
+	areas   []image.Rectangle
+	display gocv.Mat
+	scaled  gocv.Mat
+}
+
+// NewGridWindow creates a window showing the panels in cells of the given size.
+func NewGridWindow(name string, panels []*VideoPlaybackWindow, columns int, cell image.Point) *GridWindow {
+	if columns <= 0 || columns > len(panels) {
+		columns = len(panels)
+	}
+	rows := (len(panels) + columns - 1) / columns
+	g := &GridWindow{
+		Window:  gocv.NewWindow(name),
+		panels:  panels,
+		cell:    cell,
+		columns: columns,
+		areas:   make([]image.Rectangle, len(panels)),
+		display: gocv.Zeros(rows*cell.Y, columns*cell.X, gocv.MatTypeCV8UC3),
+		scaled:  gocv.NewMat(),
+	}
+	g.Window.SetMouseHandler(g.onMouse, nil)
+	return g
+}
+
+func (g *GridWindow) ApplyLayout(layout WindowLayout) {
+	applyLayout(g.Window, layout)
+}
+
+func (g *GridWindow) ToggleFullscreen() {
+	g.fullscreen = !g.fullscreen
+	setFullscreen(g.Window, g.fullscreen)
+}
+
+// PlayNextFrame redraws the cells of the panels that have a new frame.
+func (g *GridWindow) PlayNextFrame() {
+	var changed bool
+	for i, panel := range g.panels {
+		if !panel.render() {
+			continue
+		}
+		changed = true
+		g.drawCell(i, panel.display)
+	}
+	if changed {
+		g.Window.IMShow(g.display)
+	}
+}
+
+// drawCell scales the panel's frame to fit its cell, keeping the aspect ratio.
+func (g *GridWindow) drawCell(i int, img gocv.Mat) {
+	cell := image.Rect(0, 0, g.cell.X, g.cell.Y).Add(image.Pt(i%g.columns*g.cell.X, i/g.columns*g.cell.Y))
+	roi := g.display.Region(cell)
+	defer roi.Close()
+	roi.SetTo(gocv.NewScalar(0, 0, 0, 0))
+
+	size := g.cell
+	if img.Cols()*g.cell.Y > img.Rows()*g.cell.X {
+		size.Y = img.Rows() * g.cell.X / img.Cols()
+	} else {
+		size.X = img.Cols() * g.cell.Y / img.Rows()
+	}
+	if size.X <= 0 || size.Y <= 0 {
+		return
+	}
+	gocv.Resize(img, &g.scaled, size, 0, 0, gocv.InterpolationArea)
+	area := image.Rectangle{Max: size}.Add(cell.Min.Add(cell.Size().Sub(size).Div(2)))
+	dst := g.display.Region(area)
+	defer dst.Close()
+	g.scaled.CopyTo(&dst)
+	g.areas[i] = area
+}
+
+func (g *GridWindow) onMouse(event, x, y, flags int, _ any) {
+	pt := image.Pt(x, y)
+	for i, area := range g.areas {
+		panel := g.panels[i]
+		if !pt.In(area) || panel.mouse == nil {
+			continue
+		}
+		// scale back to the coordinates of the panel's frame
+		px := (x - area.Min.X) * panel.display.Cols() / area.Dx()
+		py := (y - area.Min.Y) * panel.display.Rows() / area.Dy()
+		panel.mouse(event, px, py, flags, panel.mouseData)
+		return
+	}
+}
+
+func (g *GridWindow) Close() error {
+	for _, panel := range g.panels {
+		panel.Close()
+	}
+	g.display.Close()
+	g.scaled.Close()
+	return g.Window.Close()
+}
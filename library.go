@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// Library gives access to the shots saved in the videos directory.
+type Library struct {
+	dir string
+}
+
+func NewLibrary(dir string) *Library {
+	return &Library{dir: dir}
+}
+
+// Shots returns all saved shots, oldest first.
+// Directories without a shot.json (e.g. a shot still being saved) are skipped.
+func (l *Library) Shots() ([]*Shot, error) {
+	entries, err := os.ReadDir(l.dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading videos directory: %w", err)
+	}
+	var shots []*Shot
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		shot, err := LoadShot(filepath.Join(l.dir, entry.Name()))
+		if err != nil {
+			continue
+		}
+		shots = append(shots, shot)
+	}
+	sort.Slice(shots, func(i, j int) bool {
+		return shots[i].ImpactTime.Before(shots[j].ImpactTime)
+	})
+	return shots, nil
+}
+
+// Shot returns the saved shot with the given id.
+func (l *Library) Shot(id string) (*Shot, error) {
+	if id == "" || filepath.Base(id) != id {
+		return nil, fmt.Errorf("invalid shot id %q", id)
+	}
+	return LoadShot(filepath.Join(l.dir, id))
+}
+
+// Number returns the number the shot was saved with,
+// shots saved before they were numbered are numbered by their position in the library, 1 for the oldest.
+func (l *Library) Number(shot *Shot) (int, error) {
+	if shot.Number > 0 {
+		return shot.Number, nil
+	}
+	shots, err := l.Shots()
+	if err != nil {
+		return 0, err
+	}
+	for i, s := range shots {
+		if s.ID == shot.ID {
+			return shotNumber(s, i), nil
+		}
+	}
+	return 0, fmt.Errorf("shot %s is not in the library", shot.ID)
+}
+
+// LastNumber returns the highest number of the saved shots, 0 if there are none.
+func (l *Library) LastNumber() (int, error) {
+	shots, err := l.Shots()
+	if err != nil {
+		return 0, err
+	}
+	var last int
+	for i, s := range shots {
+		last = max(last, shotNumber(s, i))
+	}
+	return last, nil
+}
+
+// shotNumber is the number of the shot at index i of the library.
+func shotNumber(shot *Shot, i int) int {
+	if shot.Number > 0 {
+		return shot.Number
+	}
+	return i + 1
+}
+
+// Delete removes the shot and all of its files.
+func (l *Library) Delete(shot *Shot) error {
+	if err := os.RemoveAll(shot.Dir()); err != nil {
+		return fmt.Errorf("error deleting shot %s: %w", shot.ID, err)
+	}
+	return nil
+}
@@ -1,30 +1,136 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"image"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"gocv.io/x/gocv"
 )
 
 func main() {
+	configFile := flag.String("config", "", "json config file, defaults are used for missing settings")
+	headless := flag.Bool("headless", false, "capture and save shots without showing any windows")
+	favorite := flag.String("favorite", "", "mark the shot with this id as a favourite and exit")
+	unfavorite := flag.String("unfavorite", "", "unmark the shot with this id as a favourite and exit")
+	web := flag.String("web", "", "serve the web ui on this address, e.g. :8080")
+	calibrate := flag.String("calibrate", "", "calibrate the lens of the camera with this name from views of a checkerboard and exit")
+	flag.Parse()
+
+	cfg := DefaultConfig()
+	if *configFile != "" {
+		var err error
+		if cfg, err = LoadConfig(*configFile); err != nil {
+			fmt.Printf("Error loading config: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	if *headless {
+		cfg.Headless = true
+	}
+	if *web != "" {
+		cfg.Web.Addr = *web
+	}
+
+	if *favorite != "" || *unfavorite != "" {
+		if err := setFavorite(cfg, *favorite, *unfavorite); err != nil {
+			fmt.Printf("Error updating favourite: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *calibrate != "" {
+		if err := Calibrate(cfg, *calibrate); err != nil {
+			fmt.Printf("Error calibrating camera: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// review shots from a browser, with or without windows, across restarts of the capture
+	var server *WebServer
+	if cfg.Web.Addr != "" {
+		server = NewWebServer(cfg, NewLibrary(cfg.VideosDir))
+		go func() {
+			if err := server.Start(); err != nil {
+				fmt.Printf("Error running web ui: %v\n", err)
+			}
+		}()
+	}
 
-	for {
-		start()
+	for !start(cfg, server) {
 	}
 }
 
-func start() {
+func setFavorite(cfg Config, favorite, unfavorite string) error {
+	library := NewLibrary(cfg.VideosDir)
+	id := favorite
+	if id == "" {
+		id = unfavorite
+	}
+	shot, err := library.Shot(id)
+	if err != nil {
+		return err
+	}
+	return shot.SetFavorite(favorite != "")
+}
+
+// start captures and replays shots until the quit command, it returns false if it has to be restarted.
+// The web server, if any, is pointed at the cameras while they capture.
+func start(cfg Config, server *WebServer) (quit bool) {
+	library := NewLibrary(cfg.VideosDir)
+	retention := NewRetention(cfg.Retention, library)
+	if cfg.Playback.ReferenceShot != "" {
+		retention.Keep(func() []string { return []string{cfg.Playback.ReferenceShot} })
+	}
+	if err := retention.Prune(); err != nil {
+		fmt.Printf("Error pruning shots: %v\n", err)
+	}
+
 	// start audio streaming
-	audio, err := NewAudio(DefaultClubStrikeDecibelThreshold)
+	audio, err := NewAudio(cfg.Audio.DecibelThreshold)
 	if err != nil {
 		fmt.Printf("Error creating audio: %v\n", err)
-		return
+		return false
 	}
-	go audio.StartDetection(DefaultMinDetectionInterval)
+	go audio.StartDetection(time.Duration(cfg.Audio.MinDetectionInterval))
 
 	// start video recording
-	video, err := NewVideoProfiles()
+	video, err := NewVideoProfiles(cfg)
 	if err != nil {
 		fmt.Printf("Error creating video profiles: %v\n", err)
-		return
+		return false
+	}
+
+	if cfg.StatsInterval > 0 {
+		stopStats := make(chan struct{})
+		defer close(stopStats)
+		go LogStats(time.Duration(cfg.StatsInterval), video, audio, stopStats)
+	}
+
+	// analyse saved shots in the background
+	analysis := NewAnalysis(cfg.Analysis)
+	defer analysis.Stop()
+	go analysis.Start()
+
+	saveShot := func(detection Detection) {
+		go func() {
+			shot, err := video.Save(detection)
+			if err != nil {
+				fmt.Printf("Error saving shot: %v\n", err)
+				return
+			}
+			analysis.Queue(shot)
+			if err := retention.Prune(); err != nil {
+				fmt.Printf("Error pruning shots: %v\n", err)
+			}
+		}()
 	}
 
 	// detect club strikes using high decibel as proxy
@@ -32,21 +138,168 @@ func start() {
 		for detection := range audio.DetectAboveThreshold() {
 			fmt.Printf(">>>>>>>> High decibel sound bite detected (%f DB @ %s), saving videos...\n",
 				detection.Decibel, detection.DetectionTime.Format("15:04:05"))
-			go video.Save(detection)
+			saveShot(detection)
 		}
 	}()
 
-	// Create a window to display the video
-	windowFront := NewVideoPlaybackWindow("Video Player Front")
-	defer windowFront.Close()
-	// Create a window to display the video
-	windowBack := NewVideoPlaybackWindow("Video Player Back")
-	defer windowBack.Close()
+	if server != nil {
+		server.SetVideo(video)
+		defer server.SetVideo(nil)
+	}
 
-	go video.Start(windowFront, windowBack)
+	if cfg.Headless {
+		fmt.Println(">>>>>>>> running headless, no windows are shown")
+		// replays are only shown in windows, so release the clips straight away
+		go func() {
+			for replay := range video.Replays() {
+				releaseClips(replay.Clips)
+			}
+		}()
+		video.Start()
+		return false
+	}
+
+	go video.Start()
+	runWindows(cfg, video, library, retention, analysis, saveShot)
+	video.Stop()
+	return true
+}
+
+// runWindows shows the replays in a window per camera until the quit command, it must run on the main thread.
+func runWindows(cfg Config, video *VideoProfiles, library *Library, retention *Retention, analysis *Analysis, saveShot func(Detection)) {
+	commands, err := NewCommandDispatcher(cfg.Keys)
+	if err != nil {
+		fmt.Printf("Error in key bindings: %v\n", err)
+		return
+	}
+
+	// replay every camera from one shared clock
+	playback := NewPlaybackController(cfg.Playback, video)
+	// never prune the shots being replayed or compared with
+	retention.Keep(playback.ShotIDs)
+	go func() {
+		for replay := range video.Replays() {
+			playback.Play(replay)
+		}
+	}()
+
+	// Create a window per camera to display the video, or panels of a single grid window
+	var windows []*VideoPlaybackWindow
+	var cameras []string
+	var screens []Screen
+	for i, camCfg := range cfg.Cameras {
+		cameras = append(cameras, camCfg.Name)
+		if cfg.Layout.Grid {
+			windows = append(windows, NewVideoPlaybackPanel(i, playback))
+			continue
+		}
+		window := NewVideoPlaybackWindow("Video Player "+strings.ToUpper(camCfg.Name[:1])+camCfg.Name[1:], i, playback)
+		placeScreen(window, cfg.Layout, camCfg.Name)
+		windows = append(windows, window)
+		screens = append(screens, window)
+	}
+	if cfg.Layout.Grid {
+		grid := NewGridWindow("Video Player", windows, cfg.Layout.GridColumns, image.Pt(cfg.Capture.Width, cfg.Capture.Height))
+		placeScreen(grid, cfg.Layout, GridWindowLayout)
+		screens = append(screens, grid)
+	}
+	for _, screen := range screens {
+		defer screen.Close()
+	}
+	if cfg.Calibration.Undistort == UndistortPlayback {
+		for i, window := range windows {
+			u, err := LoadUndistorter(cfg.Calibration.Dir, cameras[i])
+			if err != nil {
+				fmt.Printf("Error loading lens calibration, %s is shown uncorrected: %v\n", cameras[i], err)
+				continue
+			}
+			defer u.Close()
+			window.SetUndistorter(u)
+		}
+	}
+
+	// realign the replay when the analysis refines its impact frames
+	go func() {
+		for update := range analysis.Impacts() {
+			if camera := slices.Index(cameras, update.Camera); camera >= 0 {
+				playback.Control(PlaybackControl{Action: PlaybackSetImpact, Camera: camera, Frame: update.Frame, Shot: update.Shot})
+			}
+		}
+	}()
+
+	// browse earlier shots in the playback windows
+	browser := NewBrowser(library, playback, cameras, windows)
+	for _, window := range windows {
+		window.AddOverlay(browser)
+	}
+	// draw on the replays with the mouse
+	annotator := NewAnnotator(cameras, windows)
+	// shot details and the position in the clip
+	info := NewInfoOverlay(cfg.Info, library)
+	for i, window := range windows {
+		// analysis results are drawn underneath the text overlays
+		window.AddOverlay(NewPoseOverlay(analysis, cameras[i]))
+		window.AddOverlay(NewClubOverlay(analysis, cameras[i]))
+		window.AddOverlay(NewBallOverlay(analysis, cameras[i]))
+		window.AddOverlay(NewTempoOverlay(analysis, cameras[i]))
+		window.AddOverlay(NewHeadOverlay(analysis, cameras[i]))
+		window.AddOverlay(info)
+		window.AddOverlay(commands)
+	}
+
+	// keys pressed in any window run their command on every window
+	var quit bool
+	commands.Handle("quit", func(string) { quit = true })
+	commands.Handle("save", func(string) {
+		now := time.Now()
+		fmt.Printf(">>>>>>>> saving videos...\n")
+		saveShot(Detection{Source: DetectionSourceManual, DetectionTime: now, ImpactTime: now})
+	})
+	commands.Handle("fullscreen", func(string) {
+		for _, screen := range screens {
+			screen.ToggleFullscreen()
+		}
+	})
+	commands.Handle("seed_club", func(string) {
+		fmt.Println("click the club head on a replay to track it from there")
+		annotator.PickPoint(func(camera int, frame PlaybackFrame, pt image.Point) {
+			if frame.Shot == nil || frame.Live {
+				fmt.Println("the club head can only be tracked on a saved shot")
+				return
+			}
+			analysis.SeedClub(frame.Shot, cameras[camera], frame.Index, pt)
+		})
+	})
+	playback.RegisterCommands(commands)
+	browser.RegisterCommands(commands)
+	annotator.RegisterCommands(commands)
+	info.RegisterCommands(commands)
+
+	go playback.Start(windows)
 
-	for {
-		windowFront.PlayNextFrame()
-		windowBack.PlayNextFrame()
+	// compare replays with the configured reference swing
+	if cfg.Playback.ReferenceShot != "" {
+		go func() {
+			shot, err := library.Shot(cfg.Playback.ReferenceShot)
+			if err != nil {
+				fmt.Printf("Error loading reference shot: %v\n", err)
+				return
+			}
+			playback.SetReference(LoadReplay(shot, cameras))
+		}()
+	}
+
+	for !quit {
+		for _, screen := range screens {
+			screen.PlayNextFrame()
+		}
+		if key := gocv.WaitKey(1); key >= 0 {
+			commands.HandleKey(key)
+			// redraw so overlays switched by the command update while paused
+			for _, window := range windows {
+				window.Refresh()
+			}
+		}
 	}
+	fmt.Println(">>>>>>>> quitting")
 }
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// number of intervals kept to compute rates and jitter, ~2 seconds at 120 FPS
+	DefaultMetricsWindow = 240
+	// how often the health summary is logged
+	DefaultStatsInterval = 30 * time.Second
+)
+
+// CaptureStats are the health metrics of a camera.
+type CaptureStats struct {
+	Camera string
+	State  CameraState
+	// frames per second achieved over the recent window, and the requested fps
+	FPS       float64
+	TargetFPS float64
+	Frames    int64
+	// times reads started failing, the retries until the camera delivers again or reconnects count once
+	ReadFailures int64
+	Reconnects   int64
+	// standard deviation of the interval between frames
+	FrameJitter time.Duration
+	// frames held in the buffer vs its capacity
+	BufferFrames   int
+	BufferCapacity int
+	// time taken to encode the last clip, and on average
+	LastEncodeTime time.Duration
+	AvgEncodeTime  time.Duration
+	Clips          int64
+}
+
+func (s CaptureStats) String() string {
+	return fmt.Sprintf("%s [%s]: %.1f/%.0f fps, jitter %s, %d frames, %d read failures, %d reconnects, buffer %d/%d, encode %s (avg %s over %d clips)",
+		s.Camera, s.State, s.FPS, s.TargetFPS, s.FrameJitter.Round(time.Microsecond), s.Frames, s.ReadFailures, s.Reconnects,
+		s.BufferFrames, s.BufferCapacity, s.LastEncodeTime.Round(time.Millisecond), s.AvgEncodeTime.Round(time.Millisecond), s.Clips)
+}
+
+// AudioStats are the timing metrics of the audio detection.
+type AudioStats struct {
+	// interval between detection loop iterations, expected to match the detect interval
+	LoopInterval time.Duration
+	LoopJitter   time.Duration
+	// time spent computing the sound level in each iteration
+	LoopProcessing time.Duration
+	// interval between reads from the audio stream
+	ReadInterval time.Duration
+	Detections   int64
+}
+
+func (s AudioStats) String() string {
+	return fmt.Sprintf("audio: loop every %s (jitter %s, processing %s), reads every %s, %d detections",
+		s.LoopInterval.Round(time.Microsecond), s.LoopJitter.Round(time.Microsecond), s.LoopProcessing.Round(time.Microsecond),
+		s.ReadInterval.Round(time.Microsecond), s.Detections)
+}
+
+// captureMetrics collects CaptureStats from the capture loop.
+type captureMetrics struct {
+	sync.Mutex
+
+	stats     CaptureStats
+	frames    intervalStats
+	encodeSum time.Duration
+	// the last read failed
+	failing bool
+}
+
+func (m *captureMetrics) frame(t time.Time) {
+	m.Lock()
+	defer m.Unlock()
+	m.stats.Frames++
+	m.frames.tick(t)
+	m.failing = false
+}
+
+func (m *captureMetrics) readFailure() {
+	m.Lock()
+	defer m.Unlock()
+	if !m.failing {
+		m.stats.ReadFailures++
+	}
+	m.failing = true
+}
+
+func (m *captureMetrics) reconnected() {
+	m.Lock()
+	defer m.Unlock()
+	m.stats.Reconnects++
+	m.failing = false
+	// don't count the time spent disconnected as a frame interval
+	m.frames.reset()
+}
+
+func (m *captureMetrics) buffer(frames, capacity int) {
+	m.Lock()
+	defer m.Unlock()
+	m.stats.BufferFrames = frames
+	m.stats.BufferCapacity = capacity
+}
+
+func (m *captureMetrics) encoded(d time.Duration) {
+	m.Lock()
+	defer m.Unlock()
+	m.stats.Clips++
+	m.stats.LastEncodeTime = d
+	m.encodeSum += d
+	m.stats.AvgEncodeTime = m.encodeSum / time.Duration(m.stats.Clips)
+}
+
+func (m *captureMetrics) snapshot() CaptureStats {
+	m.Lock()
+	defer m.Unlock()
+	s := m.stats
+	s.FPS = m.frames.rate()
+	s.FrameJitter = m.frames.jitter()
+	return s
+}
+
+// audioMetrics collects AudioStats from the detection loop.
+type audioMetrics struct {
+	sync.Mutex
+
+	loop       intervalStats
+	reads      intervalStats
+	processing time.Duration
+	detections int64
+}
+
+func (m *audioMetrics) loopTick(t time.Time, processing time.Duration) {
+	m.Lock()
+	defer m.Unlock()
+	m.loop.tick(t)
+	m.processing = processing
+}
+
+func (m *audioMetrics) read(t time.Time) {
+	m.Lock()
+	defer m.Unlock()
+	m.reads.tick(t)
+}
+
+func (m *audioMetrics) detected() {
+	m.Lock()
+	defer m.Unlock()
+	m.detections++
+}
+
+func (m *audioMetrics) snapshot() AudioStats {
+	m.Lock()
+	defer m.Unlock()
+	return AudioStats{
+		LoopInterval:   m.loop.mean(),
+		LoopJitter:     m.loop.jitter(),
+		LoopProcessing: m.processing,
+		ReadInterval:   m.reads.mean(),
+		Detections:     m.detections,
+	}
+}
+
+// intervalStats tracks the intervals between recurring events over a sliding window.
+type intervalStats struct {
+	last      time.Time
+	intervals []time.Duration
+	next      int
+}
+
+func (s *intervalStats) tick(t time.Time) {
+	if !s.last.IsZero() {
+		if len(s.intervals) < DefaultMetricsWindow {
+			s.intervals = append(s.intervals, t.Sub(s.last))
+		} else {
+			s.intervals[s.next] = t.Sub(s.last)
+			s.next = (s.next + 1) % len(s.intervals)
+		}
+	}
+	s.last = t
+}
+
+func (s *intervalStats) reset() {
+	s.last = time.Time{}
+	s.intervals = s.intervals[:0]
+	s.next = 0
+}
+
+func (s *intervalStats) mean() time.Duration {
+	if len(s.intervals) == 0 {
+		return 0
+	}
+	var sum time.Duration
+	for _, d := range s.intervals {
+		sum += d
+	}
+	return sum / time.Duration(len(s.intervals))
+}
+
+// rate returns the events per second
+func (s *intervalStats) rate() float64 {
+	mean := s.mean()
+	if mean == 0 {
+		return 0
+	}
+	return float64(time.Second) / float64(mean)
+}
+
+// jitter returns the standard deviation of the intervals
+func (s *intervalStats) jitter() time.Duration {
+	if len(s.intervals) == 0 {
+		return 0
+	}
+	mean := float64(s.mean())
+	var sum float64
+	for _, d := range s.intervals {
+		sum += (float64(d) - mean) * (float64(d) - mean)
+	}
+	return time.Duration(math.Sqrt(sum / float64(len(s.intervals))))
+}
+
+// LogStats logs a health summary of the cameras and audio every interval until stop is closed.
+func LogStats(interval time.Duration, video *VideoProfiles, audio *Audio, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		fmt.Println("==================================================")
+		for _, s := range video.Stats() {
+			fmt.Println(s)
+		}
+		fmt.Println(audio.Stats())
+		fmt.Println("==================================================")
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestIntervalStats(t *testing.T) {
+	tests := []struct {
+		name string
+		// intervals between ticks, the stats are reset between before and after if reset is set
+		before []time.Duration
+		reset  bool
+		after  []time.Duration
+		mean   time.Duration
+		rate   float64
+		jitter time.Duration
+	}{
+		{name: "no ticks"},
+		{name: "steady", before: []time.Duration{10 * time.Millisecond, 10 * time.Millisecond, 10 * time.Millisecond}, mean: 10 * time.Millisecond, rate: 100},
+		{
+			name:   "uneven",
+			before: []time.Duration{10 * time.Millisecond, 30 * time.Millisecond, 10 * time.Millisecond, 30 * time.Millisecond},
+			mean:   20 * time.Millisecond,
+			rate:   50,
+			jitter: 10 * time.Millisecond,
+		},
+		{
+			name:   "old intervals leave the window",
+			before: repeatInterval(50*time.Millisecond, DefaultMetricsWindow),
+			after:  repeatInterval(10*time.Millisecond, DefaultMetricsWindow),
+			mean:   10 * time.Millisecond,
+			rate:   100,
+		},
+		{
+			name:   "reset drops the gap",
+			before: []time.Duration{100 * time.Millisecond, 100 * time.Millisecond},
+			reset:  true,
+			after:  []time.Duration{20 * time.Millisecond, 20 * time.Millisecond},
+			mean:   20 * time.Millisecond,
+			rate:   50,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var s intervalStats
+			now := time.Now()
+			s.tick(now)
+			for _, gap := range tt.before {
+				now = now.Add(gap)
+				s.tick(now)
+			}
+			if tt.reset {
+				// the first tick after a long time disconnected isn't an interval
+				s.reset()
+				now = now.Add(time.Minute)
+				s.tick(now)
+			}
+			for _, gap := range tt.after {
+				now = now.Add(gap)
+				s.tick(now)
+			}
+
+			if got := s.mean(); got != tt.mean {
+				t.Errorf("mean = %s, want %s", got, tt.mean)
+			}
+			if got := s.rate(); math.Abs(got-tt.rate) > 1e-9 {
+				t.Errorf("rate = %f, want %f", got, tt.rate)
+			}
+			if got := s.jitter(); got != tt.jitter {
+				t.Errorf("jitter = %s, want %s", got, tt.jitter)
+			}
+		})
+	}
+}
+
+func repeatInterval(d time.Duration, n int) []time.Duration {
+	intervals := make([]time.Duration, n)
+	for i := range intervals {
+		intervals[i] = d
+	}
+	return intervals
+}
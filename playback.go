@@ -0,0 +1,459 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+const (
+	// replays are shown at most at this rate, faster playback skips frames instead
+	DefaultPlaybackMinFrameInterval = time.Second / 60
+	// replays loop this many times before the windows go back to the live view
+	DefaultLiveAfterLoops = 3
+)
+
+// PlaybackFrame is a frame of a replay along with its position in the clip.
+type PlaybackFrame struct {
+	Frame gocv.Mat
+	Index int
+	Count int
+	// impact frame index and frame rate of the clip
+	Impact int
+	FPS    float64
+	// playback speed the frame is shown at
+	Speed float64
+	// shot being replayed, nil for unsaved clips
+	Shot *Shot
+	// the frame is from the live camera feed rather than a replay
+	Live bool
+	// how the reference swing is combined with the frame, the replay is on the left when side by side
+	Compare CompareMode
+}
+
+// LiveSource provides the latest frame of each camera for the live view.
+type LiveSource interface {
+	LatestFrame(camera int, dst *gocv.Mat) bool
+}
+
+type PlaybackAction int
+
+const (
+	PlaybackTogglePause PlaybackAction = iota
+	PlaybackStepForward
+	PlaybackStepBackward
+	PlaybackSpeedUp
+	PlaybackSpeedDown
+	PlaybackJumpToImpact
+	PlaybackRestart
+	// seek to PlaybackControl.Frame of the camera's clip
+	PlaybackSeek
+	// compare with a reference swing
+	PlaybackSetReference
+	PlaybackCycleCompare
+	PlaybackNudgeReferenceForward
+	PlaybackNudgeReferenceBackward
+	PlaybackToggleRamp
+	// switch between the live view and the last replay
+	PlaybackToggleLive
+	// play at PlaybackControl.Speed
+	PlaybackSetSpeed
+	// move the impact frame of the camera's clip of PlaybackControl.Shot to PlaybackControl.Frame
+	PlaybackSetImpact
+)
+
+type PlaybackControl struct {
+	Action PlaybackAction
+	// camera index and frame for PlaybackSeek and PlaybackSetImpact
+	Camera int
+	Frame  int
+	// speed for PlaybackSetSpeed, the closest preset is used
+	Speed float64
+	// shot for PlaybackSetImpact, ignored if another shot is being replayed
+	Shot *Shot
+}
+
+// speed presets to step through while replaying
+var PlaybackSpeeds = []float64{0.05, 0.1, 0.25, 0.5, 1, 2}
+
+// closestPlaybackSpeed returns the index of the speed preset closest to speed.
+func closestPlaybackSpeed(speed float64) int {
+	var closest int
+	for i, s := range PlaybackSpeeds {
+		if math.Abs(s-speed) < math.Abs(PlaybackSpeeds[closest]-speed) {
+			closest = i
+		}
+	}
+	return closest
+}
+
+// playbackCommands maps commands to playback controls.
+var playbackCommands = map[string]PlaybackAction{
+	"pause":              PlaybackTogglePause,
+	"step_forward":       PlaybackStepForward,
+	"step_backward":      PlaybackStepBackward,
+	"speed_up":           PlaybackSpeedUp,
+	"speed_down":         PlaybackSpeedDown,
+	"impact":             PlaybackJumpToImpact,
+	"restart":            PlaybackRestart,
+	"reference":          PlaybackSetReference,
+	"compare":            PlaybackCycleCompare,
+	"reference_forward":  PlaybackNudgeReferenceForward,
+	"reference_backward": PlaybackNudgeReferenceBackward,
+	"ramp":               PlaybackToggleRamp,
+	"live":               PlaybackToggleLive,
+}
+
+// PlaybackController replays the clips of all cameras from one shared clock, aligned on their impact frames,
+// so pausing, stepping, seeking and speed changes apply to every angle at once.
+// Between replays the windows show the live camera feed.
+type PlaybackController struct {
+	speed          int
+	ramp           SpeedRampConfig
+	compareAlpha   float64
+	live           LiveSource
+	liveAfterLoops int
+	replays        chan Replay
+	references     chan Replay
+	controls       chan PlaybackControl
+	shot           atomic.Pointer[Shot]
+	reference      atomic.Pointer[Shot]
+}
+
+// NewPlaybackController creates a controller that starts on the live view, live may be nil to only show replays.
+func NewPlaybackController(cfg PlaybackConfig, live LiveSource) *PlaybackController {
+	return &PlaybackController{
+		speed:          closestPlaybackSpeed(cfg.Speed),
+		ramp:           cfg.Ramp,
+		compareAlpha:   cfg.CompareAlpha,
+		live:           live,
+		liveAfterLoops: cfg.LiveAfterLoops,
+		replays:        make(chan Replay),
+		references:     make(chan Replay),
+		controls:       make(chan PlaybackControl, 16),
+	}
+}
+
+// Play replaces the current replay and switches from the live view to it, the controller takes ownership of the clips.
+func (p *PlaybackController) Play(replay Replay) {
+	p.replays <- replay
+}
+
+// SetReference replaces the reference swing that replays are compared with, the controller takes ownership of the clips.
+func (p *PlaybackController) SetReference(replay Replay) {
+	p.references <- replay
+}
+
+// Shot returns the shot being replayed, nil if none.
+func (p *PlaybackController) Shot() *Shot {
+	return p.shot.Load()
+}
+
+// ShotIDs returns the ids of the shots the controller holds clips of, the replay and the reference.
+func (p *PlaybackController) ShotIDs() []string {
+	var ids []string
+	for _, shot := range []*Shot{p.shot.Load(), p.reference.Load()} {
+		if shot != nil {
+			ids = append(ids, shot.ID)
+		}
+	}
+	return ids
+}
+
+// Control queues a control, dropping it if the controller isn't keeping up so the ui never blocks.
+func (p *PlaybackController) Control(c PlaybackControl) {
+	select {
+	case p.controls <- c:
+	default:
+	}
+}
+
+// RegisterCommands controls the playback with the commands of keys pressed in any window.
+func (p *PlaybackController) RegisterCommands(d *CommandDispatcher) {
+	for name, action := range playbackCommands {
+		d.Handle(name, func(string) {
+			p.Control(PlaybackControl{Action: action})
+		})
+	}
+	d.Handle("speed", func(arg string) {
+		speed, err := strconv.ParseFloat(arg, 64)
+		if err != nil || speed <= 0 {
+			fmt.Printf("invalid playback speed %q\n", arg)
+			return
+		}
+		p.Control(PlaybackControl{Action: PlaybackSetSpeed, Speed: speed})
+	})
+}
+
+// Start runs the playback clock, windows are matched to the replay's clips by camera index.
+func (p *PlaybackController) Start(windows []*VideoPlaybackWindow) {
+	var clips []*Clip
+	var shot *Shot
+	var paused bool
+	// reference swing, played at the same time relative to impact plus the offset
+	var refClips []*Clip
+	var compare CompareMode
+	var offset time.Duration
+	composed := make([]gocv.Mat, len(windows))
+	for i := range composed {
+		composed[i] = gocv.NewMat()
+		defer composed[i].Close()
+	}
+	// the clock is the time relative to impact in the clips, it runs from start to end and loops
+	var clock, start, end time.Duration
+	// one frame of the clip with the highest fps
+	var frameStep time.Duration
+	// the speed ramp froze playback on the impact frame
+	var frozen bool
+	// current playback speed, changes around impact with the speed ramp
+	var speed float64
+	// showing the live view instead of the replay, and how many times the replay looped
+	live := p.live != nil
+	var loops int
+
+	show := func() {
+		for i, clip := range clips {
+			if clip == nil || i >= len(windows) {
+				continue
+			}
+			idx := clipIndex(clip, clock)
+			frame, mode := clip.Frame(idx), CompareOff
+			if compare != CompareOff && i < len(refClips) && refClips[i] != nil {
+				ref := refClips[i]
+				composeCompare(compare, frame, ref.Frame(clipIndex(ref, clock+offset)), p.compareAlpha, &composed[i])
+				frame, mode = composed[i], compare
+			}
+			windows[i].Show(PlaybackFrame{
+				Frame:   frame,
+				Index:   idx,
+				Count:   clip.Len(),
+				Impact:  clip.ImpactFrame,
+				FPS:     clip.FPS,
+				Speed:   speed,
+				Shot:    shot,
+				Compare: mode,
+			})
+		}
+	}
+	showLive := func() {
+		for i, w := range windows {
+			if p.live.LatestFrame(i, &composed[i]) {
+				w.Show(PlaybackFrame{Frame: composed[i], Live: true})
+			}
+		}
+	}
+	setLive := func(on bool) {
+		if on == live || (on && p.live == nil) || (!on && clips == nil) {
+			return
+		}
+		live, frozen, loops = on, false, 0
+		if live {
+			fmt.Printf(">>>>>>>> showing live view\n")
+			showLive()
+			return
+		}
+		show()
+	}
+
+	for {
+		// wall time between frames and how far the clock advances in that time
+		speed = PlaybackSpeeds[p.speed]
+		if p.ramp.Enabled && frameStep > 0 {
+			// a zero impact speed would never reach the freeze, so keep moving slowly
+			speed = max(p.ramp.SpeedAt(clock, frameStep), PlaybackSpeeds[0])
+		}
+		interval := time.Duration(float64(frameStep) / speed)
+		advance := frameStep
+		if interval < DefaultPlaybackMinFrameInterval {
+			interval = DefaultPlaybackMinFrameInterval
+			advance = time.Duration(float64(interval) * speed)
+		}
+		if frozen {
+			interval, advance = time.Duration(p.ramp.Freeze), 0
+		}
+		if live {
+			interval = DefaultLiveFrameInterval
+		}
+
+		// pausing holds the replay, the live view keeps refreshing
+		var next <-chan time.Time
+		if live || (clips != nil && !paused) {
+			next = time.After(interval)
+		}
+
+		select {
+		case replay := <-p.references:
+			releaseClips(refClips)
+			refClips, offset = replay.Clips, 0
+			p.reference.Store(replay.Shot)
+			if compare == CompareOff {
+				compare = CompareSideBySide
+			}
+			if replay.Shot != nil {
+				fmt.Printf(">>>>>>>> comparing with reference shot %s\n", replay.Shot.ID)
+			}
+			if !live {
+				show()
+			}
+		case replay := <-p.replays:
+			releaseClips(clips)
+			clips, shot = replay.Clips, replay.Shot
+			p.shot.Store(shot)
+			start, end, frameStep = clipsRange(clips)
+			if frameStep == 0 {
+				// no camera saved a clip
+				clips = nil
+				continue
+			}
+			clock, frozen, live, loops = start, false, false, 0
+			show()
+		case control := <-p.controls:
+			switch control.Action {
+			case PlaybackTogglePause:
+				paused = !paused
+				continue
+			case PlaybackSpeedUp:
+				p.speed = min(p.speed+1, len(PlaybackSpeeds)-1)
+				fmt.Printf("playback speed %gx\n", PlaybackSpeeds[p.speed])
+				continue
+			case PlaybackSpeedDown:
+				p.speed = max(p.speed-1, 0)
+				fmt.Printf("playback speed %gx\n", PlaybackSpeeds[p.speed])
+				continue
+			case PlaybackSetSpeed:
+				p.speed = closestPlaybackSpeed(control.Speed)
+				fmt.Printf("playback speed %gx\n", PlaybackSpeeds[p.speed])
+				continue
+			case PlaybackToggleRamp:
+				p.ramp.Enabled = !p.ramp.Enabled
+				frozen = false
+				fmt.Printf("speed ramp enabled: %t\n", p.ramp.Enabled)
+				continue
+			case PlaybackToggleLive:
+				setLive(!live)
+				continue
+			case PlaybackSetImpact:
+				if shot == nil || control.Shot == nil || shot.ID != control.Shot.ID ||
+					control.Camera < 0 || control.Camera >= len(clips) || clips[control.Camera] == nil {
+					continue
+				}
+				clip := clips[control.Camera]
+				clip.ImpactFrame = min(max(control.Frame, 0), clip.Len()-1)
+				start, end, frameStep = clipsRange(clips)
+				clock = min(max(clock, start), end)
+				if !live {
+					show()
+				}
+				continue
+			}
+			frozen = false
+			if clips == nil {
+				continue
+			}
+			// controls of the replay bring it back from the live view
+			live, loops = false, 0
+			switch control.Action {
+			case PlaybackStepForward:
+				paused = true
+				clock = min(clock+frameStep, end)
+			case PlaybackStepBackward:
+				paused = true
+				clock = max(clock-frameStep, start)
+			case PlaybackJumpToImpact:
+				clock = 0
+			case PlaybackRestart:
+				clock = start
+			case PlaybackSeek:
+				if control.Camera < len(clips) && clips[control.Camera] != nil {
+					clip := clips[control.Camera]
+					clock = frameTime(clip, control.Frame)
+				}
+			case PlaybackSetReference:
+				releaseClips(refClips)
+				refClips, offset = make([]*Clip, len(clips)), 0
+				p.reference.Store(shot)
+				for i, clip := range clips {
+					if clip != nil {
+						refClips[i] = clip.Copy()
+					}
+				}
+				if compare == CompareOff {
+					compare = CompareSideBySide
+				}
+				fmt.Printf(">>>>>>>> replay set as the reference swing\n")
+			case PlaybackCycleCompare:
+				compare = (compare + 1) % (CompareOverlay + 1)
+				fmt.Printf("compare mode: %s\n", compare)
+			case PlaybackNudgeReferenceForward:
+				offset += frameStep
+				fmt.Printf("reference offset: %s\n", offset)
+			case PlaybackNudgeReferenceBackward:
+				offset -= frameStep
+				fmt.Printf("reference offset: %s\n", offset)
+			}
+			show()
+		case <-next:
+			if live {
+				showLive()
+				continue
+			}
+			if frozen {
+				frozen = false
+				continue
+			}
+			previous := clock
+			clock += advance
+			if p.ramp.Enabled && p.ramp.Freeze > 0 && previous < 0 && clock >= 0 {
+				clock, frozen = 0, true
+			}
+			if clock > end {
+				clock = start
+				if loops++; p.liveAfterLoops > 0 && loops >= p.liveAfterLoops && p.live != nil {
+					setLive(true)
+					continue
+				}
+				fmt.Printf(">>>>>>>> Restarting video playback\n")
+			}
+			show()
+		}
+	}
+}
+
+func releaseClips(clips []*Clip) {
+	for _, clip := range clips {
+		if clip != nil {
+			clip.Release()
+		}
+	}
+}
+
+// clipsRange returns the clock range covering all clips, and the duration of a frame of the fastest clip.
+func clipsRange(clips []*Clip) (start, end, frameStep time.Duration) {
+	for _, clip := range clips {
+		if clip == nil || clip.Len() == 0 {
+			continue
+		}
+		start = min(start, frameTime(clip, 0))
+		end = max(end, frameTime(clip, clip.Len()-1))
+		step := time.Duration(float64(time.Second) / clip.FPS)
+		if frameStep == 0 || step < frameStep {
+			frameStep = step
+		}
+	}
+	return start, end, frameStep
+}
+
+// frameTime returns the time of frame idx relative to the clip's impact frame.
+func frameTime(clip *Clip, idx int) time.Duration {
+	return time.Duration(float64(idx-clip.ImpactFrame) * float64(time.Second) / clip.FPS)
+}
+
+// clipIndex returns the clip's frame at the clock time relative to impact.
+func clipIndex(clip *Clip, clock time.Duration) int {
+	idx := clip.ImpactFrame + int(math.Round(clock.Seconds()*clip.FPS))
+	return min(max(idx, 0), clip.Len()-1)
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// testClip is a clip of empty frames, it is never released.
+func testClip(frames int, fps float64, impactFrame int) *Clip {
+	return newSharedClip("front", make([]*sharedFrame, frames), fps, impactFrame)
+}
+
+func TestClipIndex(t *testing.T) {
+	clip := testClip(100, 100, 50)
+	tests := []struct {
+		name  string
+		clock time.Duration
+		want  int
+	}{
+		{name: "impact", clock: 0, want: 50},
+		{name: "after impact", clock: 100 * time.Millisecond, want: 60},
+		{name: "before impact", clock: -200 * time.Millisecond, want: 30},
+		{name: "rounds to the nearest frame", clock: 4 * time.Millisecond, want: 50},
+		{name: "rounds up to the next frame", clock: 6 * time.Millisecond, want: 51},
+		{name: "before the clip", clock: -time.Second, want: 0},
+		{name: "after the clip", clock: time.Second, want: 99},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := clipIndex(clip, tt.clock); got != tt.want {
+				t.Errorf("clipIndex(%s) = %d, want %d", tt.clock, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClipsRange(t *testing.T) {
+	// 1s at 100fps with the impact in the middle, and 1.2s at 50fps with the impact early on
+	fast, slow := testClip(100, 100, 50), testClip(60, 50, 10)
+	tests := []struct {
+		name      string
+		clips     []*Clip
+		start     time.Duration
+		end       time.Duration
+		frameStep time.Duration
+	}{
+		{name: "no clips"},
+		{name: "no camera saved a clip", clips: []*Clip{nil, nil}},
+		{name: "one clip", clips: []*Clip{fast}, start: -500 * time.Millisecond, end: 490 * time.Millisecond, frameStep: 10 * time.Millisecond},
+		{name: "slow clip", clips: []*Clip{slow}, start: -200 * time.Millisecond, end: 980 * time.Millisecond, frameStep: 20 * time.Millisecond},
+		{name: "covers every clip at the fastest rate", clips: []*Clip{slow, fast}, start: -500 * time.Millisecond, end: 980 * time.Millisecond, frameStep: 10 * time.Millisecond},
+		{name: "skips missing clips", clips: []*Clip{nil, slow}, start: -200 * time.Millisecond, end: 980 * time.Millisecond, frameStep: 20 * time.Millisecond},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, frameStep := clipsRange(tt.clips)
+			if start != tt.start || end != tt.end || frameStep != tt.frameStep {
+				t.Errorf("clipsRange = %s, %s, %s, want %s, %s, %s", start, end, frameStep, tt.start, tt.end, tt.frameStep)
+			}
+		})
+	}
+}
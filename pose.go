@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+
+	"gocv.io/x/gocv"
+)
+
+const (
+	// name of the pose analysis and its results files
+	PoseAnalysis = "pose"
+	// OpenPose models are trained on 368x368 inputs
+	DefaultPoseInputSize = 368
+	DefaultPoseStride    = 1
+	DefaultPoseMinScore  = 0.1
+)
+
+// PoseKeypoints are the COCO body keypoints of OpenPose models, in the order of the heatmap channels.
+var PoseKeypoints = []string{
+	"nose", "neck",
+	"right_shoulder", "right_elbow", "right_wrist",
+	"left_shoulder", "left_elbow", "left_wrist",
+	"right_hip", "right_knee", "right_ankle",
+	"left_hip", "left_knee", "left_ankle",
+	"right_eye", "left_eye", "right_ear", "left_ear",
+}
+
+const (
+	poseNeck     = 1
+	poseRightHip = 8
+	poseLeftHip  = 11
+)
+
+// poseSkeleton are the keypoints joined by the bones drawn on the replay.
+var poseSkeleton = [][2]int{
+	{1, 2}, {2, 3}, {3, 4}, {1, 5}, {5, 6}, {6, 7},
+	{1, 8}, {8, 9}, {9, 10}, {1, 11}, {11, 12}, {12, 13}, {8, 11},
+	{1, 0}, {0, 14}, {14, 16}, {0, 15}, {15, 17},
+}
+
+var (
+	poseBoneColor  = color.RGBA{G: 255, A: 255}
+	poseJointColor = color.RGBA{R: 255, G: 140, A: 255}
+)
+
+// PoseConfig enables pose estimation with a model stored locally.
+type PoseConfig struct {
+	// onnx model with OpenPose COCO keypoint heatmaps as output, empty disables pose estimation
+	Model       string `json:"model"`
+	InputWidth  int    `json:"input_width"`
+	InputHeight int    `json:"input_height"`
+	// only every stride'th frame is analysed, the skeleton is held in between; raise it if analysis falls behind
+	Stride int `json:"stride"`
+	// keypoints with a lower heatmap peak are treated as not found
+	MinScore float64 `json:"min_score"`
+}
+
+func DefaultPoseConfig() PoseConfig {
+	return PoseConfig{
+		InputWidth:  DefaultPoseInputSize,
+		InputHeight: DefaultPoseInputSize,
+		Stride:      DefaultPoseStride,
+		MinScore:    DefaultPoseMinScore,
+	}
+}
+
+// PoseKeypoint is a keypoint in frame coordinates, a zero score means it wasn't found.
+type PoseKeypoint struct {
+	X     int     `json:"x"`
+	Y     int     `json:"y"`
+	Score float32 `json:"score"`
+}
+
+func (k PoseKeypoint) Point() image.Point {
+	return image.Pt(k.X, k.Y)
+}
+
+// PoseTrack is the golfer's pose through a clip.
+type PoseTrack struct {
+	Keypoints []string `json:"keypoints"`
+	Stride    int      `json:"stride"`
+	// keypoints of every stride'th frame
+	Frames [][]PoseKeypoint `json:"frames"`
+}
+
+// Frame returns the keypoints of the analysed frame closest to idx.
+func (t *PoseTrack) Frame(idx int) []PoseKeypoint {
+	if len(t.Frames) == 0 || t.Stride <= 0 {
+		return nil
+	}
+	i := int(math.Round(float64(idx) / float64(t.Stride)))
+	return t.Frames[min(max(i, 0), len(t.Frames)-1)]
+}
+
+// PoseAnalyzer estimates the golfer's pose in every frame with a DNN model on the CPU.
+type PoseAnalyzer struct {
+	cfg PoseConfig
+	net gocv.Net
+}
+
+func NewPoseAnalyzer(cfg PoseConfig) (*PoseAnalyzer, error) {
+	net := gocv.ReadNetFromONNX(cfg.Model)
+	if net.Empty() {
+		return nil, fmt.Errorf("error loading pose model %s", cfg.Model)
+	}
+	if err := net.SetPreferableBackend(gocv.NetBackendOpenCV); err != nil {
+		net.Close()
+		return nil, fmt.Errorf("error setting pose model backend: %w", err)
+	}
+	if err := net.SetPreferableTarget(gocv.NetTargetCPU); err != nil {
+		net.Close()
+		return nil, fmt.Errorf("error setting pose model target: %w", err)
+	}
+	cfg.Stride = max(cfg.Stride, 1)
+	return &PoseAnalyzer{cfg: cfg, net: net}, nil
+}
+
+func (p *PoseAnalyzer) Name() string {
+	return PoseAnalysis
+}
+
+func (p *PoseAnalyzer) Analyze(shot *Shot, clip *Clip, info *ShotClip) error {
+	track := PoseTrack{Keypoints: PoseKeypoints, Stride: p.cfg.Stride}
+	for i := 0; i < clip.Len(); i += p.cfg.Stride {
+		track.Frames = append(track.Frames, p.estimate(clip.Frame(i)))
+	}
+	return saveClipData(shot, info.Camera, PoseAnalysis, track)
+}
+
+// estimate finds each keypoint at the peak of its heatmap.
+func (p *PoseAnalyzer) estimate(frame gocv.Mat) []PoseKeypoint {
+	blob := gocv.BlobFromImage(frame, 1.0/255, image.Pt(p.cfg.InputWidth, p.cfg.InputHeight), gocv.NewScalar(0, 0, 0, 0), false, false)
+	defer blob.Close()
+	p.net.SetInput(blob, "")
+	out := p.net.Forward("")
+	defer out.Close()
+
+	// the output is N x C x H x W
+	size := gocv.GetBlobSize(out)
+	channels, height, width := int(size.Val2), int(size.Val3), int(size.Val4)
+	keypoints := make([]PoseKeypoint, len(PoseKeypoints))
+	for k := range keypoints {
+		if k >= channels || width == 0 || height == 0 {
+			break
+		}
+		heatmap := gocv.GetBlobChannel(out, 0, k)
+		_, score, _, peak := gocv.MinMaxLoc(heatmap)
+		heatmap.Close()
+		if float64(score) < p.cfg.MinScore {
+			continue
+		}
+		keypoints[k] = PoseKeypoint{
+			X:     peak.X * frame.Cols() / width,
+			Y:     peak.Y * frame.Rows() / height,
+			Score: score,
+		}
+	}
+	return keypoints
+}
+
+func (p *PoseAnalyzer) Close() error {
+	return p.net.Close()
+}
+
+// PoseOverlay draws the skeleton and spine angle of a camera's pose track.
+type PoseOverlay struct {
+	results *clipResults[PoseTrack]
+}
+
+func NewPoseOverlay(analysis *Analysis, camera string) *PoseOverlay {
+	return &PoseOverlay{results: newClipResults[PoseTrack](analysis, camera, PoseAnalysis)}
+}
+
+func (o *PoseOverlay) Draw(img *gocv.Mat, frame PlaybackFrame) {
+	if frame.Live {
+		return
+	}
+	track := o.results.get(frame.Shot)
+	if track == nil {
+		return
+	}
+	keypoints := track.Frame(frame.Index)
+	found := func(k int) bool {
+		return k < len(keypoints) && keypoints[k].Score > 0
+	}
+	for _, bone := range poseSkeleton {
+		if found(bone[0]) && found(bone[1]) {
+			gocv.Line(img, keypoints[bone[0]].Point(), keypoints[bone[1]].Point(), poseBoneColor, 2)
+		}
+	}
+	for k := range keypoints {
+		if found(k) {
+			gocv.Circle(img, keypoints[k].Point(), 4, poseJointColor, -1)
+		}
+	}
+
+	// spine angle from vertical, between the neck and the middle of the hips
+	if found(poseNeck) && found(poseRightHip) && found(poseLeftHip) {
+		neck := keypoints[poseNeck].Point()
+		hips := keypoints[poseRightHip].Point().Add(keypoints[poseLeftHip].Point()).Div(2)
+		d := neck.Sub(hips)
+		angle := math.Abs(math.Atan2(float64(d.X), float64(-d.Y)) * 180 / math.Pi)
+		text := fmt.Sprintf("spine %.0f deg", angle)
+		gocv.PutText(img, text, neck.Add(image.Pt(15, 0)), gocv.FontHersheySimplex, 0.6, poseBoneColor, 2)
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"math"
+	"time"
+)
+
+const (
+	// near real time during the takeaway and finish
+	DefaultRampSpeed = 0.75
+	// super slow through impact
+	DefaultRampImpactSpeed = 0.05
+	// frames at the clip's fps over which the speed ramps down before impact and back up after it
+	DefaultRampFramesBefore = 90
+	DefaultRampFramesAfter  = 60
+	// frames either side of impact played at the impact speed
+	DefaultRampHoldFrames = 6
+)
+
+// SpeedRampConfig is a playback profile that slows down progressively towards the impact frame and speeds up through the finish.
+type SpeedRampConfig struct {
+	Enabled     bool    `json:"enabled"`
+	Speed       float64 `json:"speed"`
+	ImpactSpeed float64 `json:"impact_speed"`
+	// frames relative to the impact frame
+	FramesBefore int `json:"frames_before"`
+	FramesAfter  int `json:"frames_after"`
+	HoldFrames   int `json:"hold_frames"`
+	// how long to freeze on the impact frame, 0 doesn't freeze
+	Freeze Duration `json:"freeze"`
+}
+
+func DefaultSpeedRampConfig() SpeedRampConfig {
+	return SpeedRampConfig{
+		Speed:        DefaultRampSpeed,
+		ImpactSpeed:  DefaultRampImpactSpeed,
+		FramesBefore: DefaultRampFramesBefore,
+		FramesAfter:  DefaultRampFramesAfter,
+		HoldFrames:   DefaultRampHoldFrames,
+	}
+}
+
+// SpeedAt returns the playback speed at the clock time relative to impact, with frameStep the duration of a frame.
+func (r SpeedRampConfig) SpeedAt(clock, frameStep time.Duration) float64 {
+	frame := int(math.Round(float64(clock) / float64(frameStep)))
+	distance, ramp := -frame, r.FramesBefore
+	if frame > 0 {
+		distance, ramp = frame, r.FramesAfter
+	}
+	distance -= r.HoldFrames
+	if distance <= 0 {
+		return r.ImpactSpeed
+	}
+	if distance >= ramp {
+		return r.Speed
+	}
+	// ease in and out of the slow motion
+	t := float64(distance) / float64(ramp)
+	t = t * t * (3 - 2*t)
+	return r.ImpactSpeed + (r.Speed-r.ImpactSpeed)*t
+}
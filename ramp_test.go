@@ -0,0 +1,36 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestSpeedAt(t *testing.T) {
+	ramp := SpeedRampConfig{Speed: 1, ImpactSpeed: 0, FramesBefore: 10, FramesAfter: 4, HoldFrames: 2}
+	frameStep := 10 * time.Millisecond
+	tests := []struct {
+		name  string
+		clock time.Duration
+		want  float64
+	}{
+		{name: "impact", clock: 0, want: 0},
+		{name: "held after impact", clock: 20 * time.Millisecond, want: 0},
+		{name: "held before impact", clock: -20 * time.Millisecond, want: 0},
+		{name: "eases out after the hold", clock: 30 * time.Millisecond, want: 0.15625},
+		{name: "halfway up after impact", clock: 40 * time.Millisecond, want: 0.5},
+		{name: "full speed after the ramp", clock: 60 * time.Millisecond, want: 1},
+		{name: "halfway down before impact", clock: -70 * time.Millisecond, want: 0.5},
+		{name: "full speed before the ramp", clock: -120 * time.Millisecond, want: 1},
+		{name: "takeaway", clock: -time.Second, want: 1},
+		{name: "rounds down to the held frame", clock: 24 * time.Millisecond, want: 0},
+		{name: "rounds up to the next frame", clock: 26 * time.Millisecond, want: 0.15625},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ramp.SpeedAt(tt.clock, frameStep); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("SpeedAt(%s) = %f, want %f", tt.clock, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Retention prunes saved shots so the videos directory stays within the configured limits.
+// Prunes run one at a time, as every saved shot starts one.
+type Retention struct {
+	mu      sync.Mutex
+	cfg     RetentionConfig
+	library *Library
+	// return the ids of shots in use, which are kept like favourites
+	inUse []func() []string
+}
+
+func NewRetention(cfg RetentionConfig, library *Library) *Retention {
+	if cfg.MaxTotalBytes > 0 || cfg.MaxAge > 0 || cfg.MaxShots > 0 {
+		fmt.Printf(">>>>>>>> retention: the oldest shots are deleted beyond %.1f MB, %s or %d shots (0 is no limit), favourites are kept\n",
+			float64(cfg.MaxTotalBytes)/(1<<20), time.Duration(cfg.MaxAge), cfg.MaxShots)
+	}
+	return &Retention{
+		cfg:     cfg,
+		library: library,
+	}
+}
+
+// Keep protects the shots whose ids fn returns when pruning, e.g. the shots being replayed.
+func (r *Retention) Keep(fn func() []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.inUse = append(r.inUse, fn)
+}
+
+// Prune deletes the oldest shots until the library is within the limits on total size, age and shot count.
+// Favourite shots, shots in use and the latest shot are never deleted, but still count towards the limits.
+func (r *Retention) Prune() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var inUse []string
+	for _, fn := range r.inUse {
+		inUse = append(inUse, fn()...)
+	}
+
+	shots, err := r.library.Shots()
+	if err != nil {
+		return err
+	}
+
+	sizes := make([]int64, len(shots))
+	var totalBytes int64
+	for i, shot := range shots {
+		if sizes[i], err = shot.Size(); err != nil {
+			return err
+		}
+		totalBytes += sizes[i]
+	}
+
+	count := len(shots)
+	// shots are sorted oldest first
+	for i, shot := range shots {
+		tooOld := r.cfg.MaxAge > 0 && time.Since(shot.ImpactTime) > time.Duration(r.cfg.MaxAge)
+		tooBig := r.cfg.MaxTotalBytes > 0 && totalBytes > r.cfg.MaxTotalBytes
+		tooMany := r.cfg.MaxShots > 0 && count > r.cfg.MaxShots
+		if !tooOld && !tooBig && !tooMany {
+			break
+		}
+		// never remove the latest shot, it is still being replayed
+		if i == len(shots)-1 {
+			break
+		}
+		if shot.Favorite || slices.Contains(inUse, shot.ID) {
+			continue
+		}
+		if err := r.library.Delete(shot); err != nil {
+			return err
+		}
+		totalBytes -= sizes[i]
+		count--
+		fmt.Printf(">>>>>>>> retention: removed shot %s (%.1f MB), %d shots using %.1f MB remain\n",
+			shot.ID, float64(sizes[i])/(1<<20), count, float64(totalBytes)/(1<<20))
+	}
+	if r.cfg.MaxTotalBytes > 0 && totalBytes > r.cfg.MaxTotalBytes {
+		fmt.Printf("retention: protected shots alone use %.1f MB, above the %.1f MB limit\n",
+			float64(totalBytes)/(1<<20), float64(r.cfg.MaxTotalBytes)/(1<<20))
+	}
+	return nil
+}
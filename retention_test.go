@@ -0,0 +1,116 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRetentionPrune(t *testing.T) {
+	// shots from oldest to newest, each with 10KB of video
+	type shot struct {
+		age      time.Duration
+		favorite bool
+	}
+	tests := []struct {
+		name  string
+		cfg   RetentionConfig
+		shots []shot
+		// indexes of the shots in use
+		inUse []int
+		// indexes of the shots left
+		want []int
+	}{
+		{
+			name:  "no limits",
+			shots: []shot{{age: 3 * time.Hour}, {age: 2 * time.Hour}, {age: time.Hour}},
+			want:  []int{0, 1, 2},
+		},
+		{
+			name:  "max shots deletes the oldest",
+			cfg:   RetentionConfig{MaxShots: 2},
+			shots: []shot{{age: 4 * time.Hour}, {age: 3 * time.Hour}, {age: 2 * time.Hour}, {age: time.Hour}},
+			want:  []int{2, 3},
+		},
+		{
+			name:  "max total bytes deletes the oldest",
+			cfg:   RetentionConfig{MaxTotalBytes: 25_000},
+			shots: []shot{{age: 4 * time.Hour}, {age: 3 * time.Hour}, {age: 2 * time.Hour}, {age: time.Hour}},
+			want:  []int{2, 3},
+		},
+		{
+			name:  "max age",
+			cfg:   RetentionConfig{MaxAge: Duration(90 * time.Minute)},
+			shots: []shot{{age: 3 * time.Hour}, {age: 2 * time.Hour}, {age: time.Hour}, {age: 10 * time.Minute}},
+			want:  []int{2, 3},
+		},
+		{
+			name:  "favourites are kept and still count",
+			cfg:   RetentionConfig{MaxShots: 2},
+			shots: []shot{{age: 4 * time.Hour, favorite: true}, {age: 3 * time.Hour}, {age: 2 * time.Hour}, {age: time.Hour}},
+			want:  []int{0, 3},
+		},
+		{
+			name:  "shots in use are kept and still count",
+			cfg:   RetentionConfig{MaxShots: 2},
+			shots: []shot{{age: 4 * time.Hour}, {age: 3 * time.Hour}, {age: 2 * time.Hour}, {age: time.Hour}},
+			inUse: []int{1},
+			want:  []int{1, 3},
+		},
+		{
+			name:  "the latest shot is kept",
+			cfg:   RetentionConfig{MaxAge: Duration(time.Minute)},
+			shots: []shot{{age: 3 * time.Hour}, {age: 2 * time.Hour}, {age: time.Hour}},
+			want:  []int{2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			now := time.Now()
+			var ids []string
+			for _, s := range tt.shots {
+				impact := now.Add(-s.age)
+				id := impact.Format(ShotIDFormat)
+				shotDir := filepath.Join(dir, id)
+				if err := os.MkdirAll(shotDir, 0o755); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filepath.Join(shotDir, "front.avi"), make([]byte, 10_000), 0o644); err != nil {
+					t.Fatal(err)
+				}
+				shot := &Shot{dir: shotDir, ID: id, ImpactTime: impact, Favorite: s.favorite}
+				if err := shot.Save(); err != nil {
+					t.Fatal(err)
+				}
+				ids = append(ids, id)
+			}
+
+			library := NewLibrary(dir)
+			retention := NewRetention(tt.cfg, library)
+			retention.Keep(func() []string {
+				var inUse []string
+				for _, i := range tt.inUse {
+					inUse = append(inUse, ids[i])
+				}
+				return inUse
+			})
+			if err := retention.Prune(); err != nil {
+				t.Fatalf("Prune: %v", err)
+			}
+			shots, err := library.Shots()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []int
+			for _, shot := range shots {
+				got = append(got, slices.Index(ids, shot.ID))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("shots left %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
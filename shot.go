@@ -0,0 +1,291 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	// name of the metadata sidecar written into every shot directory
+	ShotMetadataFile = "shot.json"
+	// shot directories are named after the impact time in this format
+	ShotIDFormat = "2006-01-02 15-04-05"
+)
+
+// Shot is a single detected strike, saved into its own directory with one clip per camera
+// and a shot.json sidecar describing it.
+type Shot struct {
+	mu  sync.Mutex
+	dir string
+	// closed once the clips of a new shot are written, nil for a shot loaded from disk
+	saving chan struct{}
+
+	ID string `json:"id"`
+	// counts the shots saved, 1 for the first, 0 for shots saved before they were numbered
+	Number int `json:"number,omitempty"`
+	// what triggered the shot (e.g. audio) and the level measured by the detector
+	Source string  `json:"source"`
+	Level  float64 `json:"level"`
+	// best estimate of when the club struck the ball
+	ImpactTime    time.Time    `json:"impact_time"`
+	DetectionTime time.Time    `json:"detection_time"`
+	Clips         []ShotClip   `json:"clips"`
+	Settings      ShotSettings `json:"settings"`
+	// favourite shots are never pruned by the retention policy
+	Favorite bool     `json:"favorite"`
+	Club     string   `json:"club,omitempty"`
+	Tags     []string `json:"tags,omitempty"`
+}
+
+// ShotClip describes the clip recorded by one camera for a shot.
+type ShotClip struct {
+	Camera string `json:"camera"`
+	// file name relative to the shot directory
+	File   string `json:"file"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+	// fps reported by the camera and fps measured from the frame timestamps
+	FPS         float64 `json:"fps"`
+	MeasuredFPS float64 `json:"measured_fps"`
+	Frames      int     `json:"frames"`
+	// index of the frame captured closest to the impact time, refined from the video by the impact analysis
+	ImpactFrame int `json:"impact_frame"`
+	// index of the frame closest to the impact time before it was refined
+	AudioImpactFrame int `json:"audio_impact_frame,omitempty"`
+	// video captured before and after the impact
+	PreRoll  Duration `json:"pre_roll"`
+	PostRoll Duration `json:"post_roll"`
+	// camera controls in effect, as accepted by the driver
+	Controls []CameraControl `json:"controls,omitempty"`
+	// the lens distortion was corrected as the frames were captured
+	Undistorted bool `json:"undistorted,omitempty"`
+	// ball launch found by the ball analysis
+	Launch *BallLaunch `json:"launch,omitempty"`
+	// swing phases found by the tempo analysis
+	Tempo *SwingTempo `json:"tempo,omitempty"`
+	// head movement found by the head tracking
+	Head *HeadSway `json:"head,omitempty"`
+}
+
+// PlaybackFPS returns the frame rate the clip is played at, the camera's reported fps when it has one.
+func (c ShotClip) PlaybackFPS() float64 {
+	switch {
+	case c.FPS > 0:
+		return c.FPS
+	case c.MeasuredFPS > 0:
+		return c.MeasuredFPS
+	}
+	return DefaultFPS
+}
+
+// ShotSettings are the settings in effect when the shot was captured.
+type ShotSettings struct {
+	Audio    AudioConfig    `json:"audio"`
+	Capture  CaptureConfig  `json:"capture"`
+	Playback PlaybackConfig `json:"playback"`
+}
+
+// NewShot creates the directory for a new shot in videosDir.
+// Shots in the same second get a suffix, e.g. "-2", so they never share a directory.
+func NewShot(videosDir string, detection Detection, cfg Config) (*Shot, error) {
+	if err := os.MkdirAll(videosDir, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating videos directory: %w", err)
+	}
+	base := detection.ImpactTime.Format(ShotIDFormat)
+	id := base
+	dir := filepath.Join(videosDir, id)
+	for n := 2; ; n++ {
+		err := os.Mkdir(dir, 0o755)
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, fmt.Errorf("error creating shot directory %s: %w", dir, err)
+		}
+		id = fmt.Sprintf("%s-%d", base, n)
+		dir = filepath.Join(videosDir, id)
+	}
+	return &Shot{
+		dir:           dir,
+		saving:        make(chan struct{}),
+		ID:            id,
+		Source:        detection.Source,
+		Level:         detection.Decibel,
+		ImpactTime:    detection.ImpactTime,
+		DetectionTime: detection.DetectionTime,
+		Club:          cfg.Club,
+		Settings: ShotSettings{
+			Audio:    cfg.Audio,
+			Capture:  cfg.Capture,
+			Playback: cfg.Playback,
+		},
+	}, nil
+}
+
+// LoadShot reads the shot stored in dir.
+func LoadShot(dir string) (*Shot, error) {
+	b, err := os.ReadFile(filepath.Join(dir, ShotMetadataFile))
+	if err != nil {
+		return nil, fmt.Errorf("error reading shot metadata: %w", err)
+	}
+	s := &Shot{dir: dir}
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, fmt.Errorf("error parsing shot metadata %s: %w", dir, err)
+	}
+	return s, nil
+}
+
+func (s *Shot) Dir() string {
+	return s.dir
+}
+
+// Path returns the path of a file stored in the shot directory.
+func (s *Shot) Path(file string) string {
+	return filepath.Join(s.dir, file)
+}
+
+// AddClip records the clip saved by a camera.
+func (s *Shot) AddClip(clip ShotClip) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Clips = append(s.Clips, clip)
+}
+
+// UpdateClip replaces the description of a camera's clip, e.g. with analysis results.
+func (s *Shot) UpdateClip(clip ShotClip) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, c := range s.Clips {
+		if c.Camera == clip.Camera {
+			s.Clips[i] = clip
+		}
+	}
+}
+
+// Cameras returns the cameras the shot has clips of.
+func (s *Shot) Cameras() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var cameras []string
+	for _, c := range s.Clips {
+		cameras = append(cameras, c.Camera)
+	}
+	return cameras
+}
+
+// Clip returns the clip recorded by camera.
+func (s *Shot) Clip(camera string) (ShotClip, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.Clips {
+		if c.Camera == camera {
+			return c, true
+		}
+	}
+	return ShotClip{}, false
+}
+
+// Trigger describes what triggered the shot, the level measured for audio detections.
+func (s *Shot) Trigger() string {
+	if s.Source == DetectionSourceAudio {
+		return fmt.Sprintf("%.1f dB", s.Level)
+	}
+	return s.Source
+}
+
+// Tempo returns the swing tempo of the first clip it was found on, nil if it wasn't.
+func (s *Shot) Tempo() *SwingTempo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, c := range s.Clips {
+		if c.Tempo != nil {
+			return c.Tempo
+		}
+	}
+	return nil
+}
+
+// SetFavorite marks or unmarks the shot as a favourite.
+func (s *Shot) SetFavorite(favorite bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Favorite = favorite
+	return s.save()
+}
+
+// ToggleTag adds the tag, or removes it if the shot already has it, and reports whether the shot now has it.
+func (s *Shot) ToggleTag(tag string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if i := slices.Index(s.Tags, tag); i >= 0 {
+		s.Tags = slices.Delete(s.Tags, i, i+1)
+		return false, s.save()
+	}
+	s.Tags = append(s.Tags, tag)
+	return true, s.save()
+}
+
+// Size returns the total size of the files in the shot directory.
+func (s *Shot) Size() (int64, error) {
+	var size int64
+	err := filepath.WalkDir(s.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		size += info.Size()
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("error measuring shot %s: %w", s.ID, err)
+	}
+	return size, nil
+}
+
+// WaitSaved waits until the clips of a new shot are written and its metadata is saved, or saving failed.
+func (s *Shot) WaitSaved() {
+	if s.saving != nil {
+		<-s.saving
+	}
+}
+
+// Save writes the shot.json sidecar, replacing it atomically so readers never see a partial file.
+func (s *Shot) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.save()
+}
+
+func (s *Shot) save() error {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error encoding shot metadata: %w", err)
+	}
+	if err := writeFileAtomic(s.Path(ShotMetadataFile), b); err != nil {
+		return fmt.Errorf("error writing shot metadata: %w", err)
+	}
+	return nil
+}
+
+// writeFileAtomic writes the file next to its destination and renames it into place,
+// so readers and a crash never leave a partial file.
+func writeFileAtomic(file string, b []byte) error {
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
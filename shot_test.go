@@ -0,0 +1,63 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestNewShotSameSecond(t *testing.T) {
+	impact := time.Date(2026, 5, 1, 9, 30, 15, 0, time.Local)
+	tests := []struct {
+		name  string
+		times []time.Time
+		want  []string
+	}{
+		{name: "one shot", times: []time.Time{impact}, want: []string{"2026-05-01 09-30-15"}},
+		{
+			name:  "different seconds",
+			times: []time.Time{impact, impact.Add(time.Second)},
+			want:  []string{"2026-05-01 09-30-15", "2026-05-01 09-30-16"},
+		},
+		{
+			name:  "same second",
+			times: []time.Time{impact, impact.Add(300 * time.Millisecond), impact.Add(600 * time.Millisecond)},
+			want:  []string{"2026-05-01 09-30-15", "2026-05-01 09-30-15-2", "2026-05-01 09-30-15-3"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			var got []string
+			for _, at := range tt.times {
+				shot, err := NewShot(dir, Detection{Source: DetectionSourceManual, ImpactTime: at, DetectionTime: at}, DefaultConfig())
+				if err != nil {
+					t.Fatalf("NewShot: %v", err)
+				}
+				got = append(got, shot.ID)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("shot ids %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShotTrigger(t *testing.T) {
+	tests := []struct {
+		name string
+		shot *Shot
+		want string
+	}{
+		{name: "audio", shot: &Shot{Source: DetectionSourceAudio, Level: 84.26}, want: "84.3 dB"},
+		{name: "manual", shot: &Shot{Source: DetectionSourceManual}, want: "manual"},
+		{name: "manual ignores the level", shot: &Shot{Source: DetectionSourceManual, Level: 60}, want: "manual"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.shot.Trigger(); got != tt.want {
+				t.Errorf("Trigger() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+const (
+	// name of the swing phase analysis
+	TempoAnalysis = "tempo"
+	// the top of the backswing is searched for this long before impact
+	DefaultTempoMinDownswing = 150 * time.Millisecond
+	DefaultTempoMaxDownswing = 600 * time.Millisecond
+	// the takeaway is searched for this long before the top
+	DefaultTempoMaxBackswing = 1500 * time.Millisecond
+	// motion below this fraction of the way from the stillest frame to the fastest counts as standing still
+	DefaultTempoStillFraction = 0.1
+	// frames are scaled down to this width to measure motion
+	DefaultTempoMotionWidth = 320
+)
+
+var tempoColor = color.RGBA{R: 255, G: 255, B: 255, A: 255}
+
+// TempoConfig enables swing phase detection on saved shots, it is off unless enabled in the config
+// as it decodes every clip of each shot.
+type TempoConfig struct {
+	Enabled bool `json:"enabled"`
+}
+
+// SwingTempo is the first frame of each swing phase and the timing of the swing.
+type SwingTempo struct {
+	Address  int `json:"address"`
+	Takeaway int `json:"takeaway"`
+	Top      int `json:"top"`
+	Impact   int `json:"impact"`
+	Finish   int `json:"finish"`
+	// takeaway to top and top to impact
+	Backswing Duration `json:"backswing"`
+	Downswing Duration `json:"downswing"`
+	// backswing time over downswing time, around 3 for most good swings
+	Ratio float64 `json:"ratio"`
+}
+
+// Phase returns the name of the swing phase frame idx is in.
+func (t *SwingTempo) Phase(idx int) string {
+	switch {
+	case idx >= t.Finish:
+		return "finish"
+	case idx >= t.Impact:
+		return "follow through"
+	case idx >= t.Top:
+		return "downswing"
+	case idx >= t.Takeaway:
+		return "backswing"
+	case idx >= t.Address:
+		return "address"
+	}
+	return ""
+}
+
+// TempoAnalyzer finds the swing phases from the motion in the clip, anchored to the impact frame.
+// The top of the backswing is taken from the club head track when there is one.
+type TempoAnalyzer struct {
+	cfg TempoConfig
+}
+
+func NewTempoAnalyzer(cfg TempoConfig) *TempoAnalyzer {
+	return &TempoAnalyzer{cfg: cfg}
+}
+
+func (t *TempoAnalyzer) Name() string {
+	return TempoAnalysis
+}
+
+func (t *TempoAnalyzer) Analyze(shot *Shot, clip *Clip, info *ShotClip) error {
+	impact := clip.ImpactFrame
+	if impact < 1 || impact >= clip.Len() {
+		return fmt.Errorf("impact frame %d is at the edge of the clip", impact)
+	}
+	if clip.FPS <= 0 {
+		return fmt.Errorf("clip has no frame rate")
+	}
+	frames := func(d time.Duration) int {
+		return int(clip.FPS * d.Seconds())
+	}
+	energy := motionEnergy(clip)
+
+	// the club changes direction at the top, so the top is the least motion shortly before impact
+	lo, hi := max(1, impact-frames(DefaultTempoMaxDownswing)), impact-frames(DefaultTempoMinDownswing)
+	if hi <= lo {
+		return fmt.Errorf("clip is too short before impact")
+	}
+	top := lo
+	for i := lo; i < hi; i++ {
+		if energy[i] < energy[top] {
+			top = i
+		}
+	}
+	var track ClubTrack
+	if err := loadClipData(shot, info.Camera, ClubAnalysis, &track); err == nil {
+		if i := track.Top(impact); i >= 0 && track.Points[i].Frame >= lo {
+			top = track.Points[i].Frame
+		}
+	}
+
+	still, peak := energy[1], energy[1]
+	for _, e := range energy[1:] {
+		still, peak = min(still, e), max(peak, e)
+	}
+	threshold := still + DefaultTempoStillFraction*(peak-still)
+
+	// the takeaway is where the motion of the backswing started, walking back from its fastest frame
+	fastest := top
+	for i := max(1, top-frames(DefaultTempoMaxBackswing)); i < top; i++ {
+		if energy[i] > energy[fastest] {
+			fastest = i
+		}
+	}
+	takeaway := -1
+	for i := fastest; i > 0; i-- {
+		if energy[i] < threshold {
+			takeaway = i + 1
+			break
+		}
+	}
+	if takeaway < 0 || takeaway >= top {
+		return fmt.Errorf("no address found before the backswing")
+	}
+	address := takeaway - 1
+	for address > 1 && energy[address-1] < threshold {
+		address--
+	}
+	finish := clip.Len() - 1
+	for i := impact + 1; i < clip.Len(); i++ {
+		if energy[i] < threshold {
+			finish = i
+			break
+		}
+	}
+
+	duration := func(from, to int) Duration {
+		return Duration(float64(to-from) / clip.FPS * float64(time.Second))
+	}
+	info.Tempo = &SwingTempo{
+		Address:   address,
+		Takeaway:  takeaway,
+		Top:       top,
+		Impact:    impact,
+		Finish:    finish,
+		Backswing: duration(takeaway, top),
+		Downswing: duration(top, impact),
+		Ratio:     float64(top-takeaway) / float64(impact-top),
+	}
+	fmt.Printf("%s clip of shot %s: backswing %s, downswing %s, tempo %.1f:1\n", info.Camera, shot.ID,
+		info.Tempo.Backswing, info.Tempo.Downswing, info.Tempo.Ratio)
+	return nil
+}
+
+// motionEnergy is the mean difference of each frame from the one before, averaged over a few frames.
+func motionEnergy(clip *Clip) []float64 {
+	energy := make([]float64, clip.Len())
+	diff := gocv.NewMat()
+	defer diff.Close()
+	var prev gocv.Mat
+	for i := 0; i < clip.Len(); i++ {
+		gray := grayFrame(clip.Frame(i))
+		scale := float64(DefaultTempoMotionWidth) / float64(max(gray.Cols(), 1))
+		gocv.Resize(gray, &gray, image.Point{}, scale, scale, gocv.InterpolationArea)
+		if i > 0 {
+			gocv.AbsDiff(prev, gray, &diff)
+			energy[i] = diff.Mean().Val1
+			prev.Close()
+		}
+		prev = gray
+	}
+	if clip.Len() > 0 {
+		prev.Close()
+	}
+
+	smoothed := make([]float64, len(energy))
+	for i := 1; i < len(energy); i++ {
+		from, to := max(1, i-2), min(len(energy), i+3)
+		var sum float64
+		for _, e := range energy[from:to] {
+			sum += e
+		}
+		smoothed[i] = sum / float64(to-from)
+	}
+	return smoothed
+}
+
+func (t *TempoAnalyzer) Close() error {
+	return nil
+}
+
+// TempoOverlay shows the swing phase of the frame and the swing's timing.
+type TempoOverlay struct {
+	results *clipResults[ShotClip]
+}
+
+func NewTempoOverlay(analysis *Analysis, camera string) *TempoOverlay {
+	return &TempoOverlay{results: newClipInfoResults(analysis, camera, TempoAnalysis)}
+}
+
+func (o *TempoOverlay) Draw(img *gocv.Mat, frame PlaybackFrame) {
+	if frame.Live {
+		return
+	}
+	info := o.results.get(frame.Shot)
+	if info == nil || info.Tempo == nil {
+		return
+	}
+	tempo := info.Tempo
+	text := fmt.Sprintf("tempo %.1f:1  back %.2fs  down %.2fs", tempo.Ratio,
+		time.Duration(tempo.Backswing).Seconds(), time.Duration(tempo.Downswing).Seconds())
+	// centred at the top, clear of the shot list and the help
+	lines := []string{text, tempo.Phase(frame.Index)}
+	for i, line := range lines {
+		size := gocv.GetTextSize(line, gocv.FontHersheySimplex, 0.7, 2)
+		gocv.PutText(img, line, image.Pt((img.Cols()-size.X)/2, 30*(i+1)), gocv.FontHersheySimplex, 0.7, tempoColor, 2)
+	}
+}
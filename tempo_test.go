@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestSwingTempoPhase(t *testing.T) {
+	tempo := &SwingTempo{Address: 10, Takeaway: 40, Top: 100, Impact: 130, Finish: 180}
+	tests := []struct {
+		idx  int
+		want string
+	}{
+		{idx: 0, want: ""},
+		{idx: 10, want: "address"},
+		{idx: 39, want: "address"},
+		{idx: 40, want: "backswing"},
+		{idx: 99, want: "backswing"},
+		{idx: 100, want: "downswing"},
+		{idx: 129, want: "downswing"},
+		{idx: 130, want: "follow through"},
+		{idx: 179, want: "follow through"},
+		{idx: 180, want: "finish"},
+		{idx: 500, want: "finish"},
+	}
+	for _, tt := range tests {
+		if got := tempo.Phase(tt.idx); got != tt.want {
+			t.Errorf("Phase(%d) = %q, want %q", tt.idx, got, tt.want)
+		}
+	}
+}
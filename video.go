@@ -2,7 +2,9 @@ package main
 
 import (
 	"fmt"
+	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"gocv.io/x/gocv"
@@ -20,310 +22,547 @@ const (
 	DefaultSecondsToRecord = 4
 	// duration of video to capture after event
 	DefaultDurationToCaptureAfterEvent = DefaultSecondsToRecord * time.Second / 2
+	// live views are refreshed at up to 30 FPS
+	DefaultLiveFrameInterval = time.Second / 30
 )
 
 type VideoProfileEnum string
 
-// Manages 2 video streams to capture both front & side profile.
+// Manages the video streams of all cameras, e.g. to capture both front & side profile.
 type VideoProfiles struct {
-	front *VideoProfile
-	back  *VideoProfile
+	cfg      Config
+	profiles []*VideoProfile
+	replays  chan Replay
+	// number of the latest shot, shots still being saved aren't in the library yet
+	numberMu   sync.Mutex
+	lastNumber int
 }
 
-func NewVideoProfiles() (*VideoProfiles, error) {
-	front, err := NewVideoProfile("front", 0, DefaultDurationToCaptureAfterEvent)
+// nextNumber numbers a new shot after the latest one saved, so numbers don't change when older shots are deleted.
+func (v *VideoProfiles) nextNumber() int {
+	v.numberMu.Lock()
+	defer v.numberMu.Unlock()
+	last, err := NewLibrary(v.cfg.VideosDir).LastNumber()
 	if err != nil {
-		return nil, fmt.Errorf("error opening front camera (0): %w", err)
-	}
-	back, err := NewVideoProfile("back", 1, DefaultDurationToCaptureAfterEvent)
-	if err != nil {
-		return nil, fmt.Errorf("error opening back camera (1): %w", err)
+		fmt.Printf("error numbering shot: %v\n", err)
 	}
+	v.lastNumber = max(v.lastNumber, last) + 1
+	return v.lastNumber
+}
+
+// Replay is a shot that was just saved, with the in-memory clip of each camera ready to be replayed.
+type Replay struct {
+	Shot *Shot
+	// clips by camera index, nil if the camera failed to save, the receiver owns the clips
+	Clips []*Clip
+}
+
+func NewVideoProfiles(cfg Config) (*VideoProfiles, error) {
 	v := &VideoProfiles{
-		front: front,
-		back:  back,
+		cfg:     cfg,
+		replays: make(chan Replay, 1),
+	}
+	for _, camCfg := range cfg.Cameras {
+		profile, err := NewVideoProfile(camCfg, cfg)
+		if err != nil {
+			return nil, err
+		}
+		v.profiles = append(v.profiles, profile)
 	}
 	return v, nil
 }
 
-func (v *VideoProfiles) Start(frontWindow, backWindow *VideoPlaybackWindow) {
+func (v *VideoProfiles) Start() {
+	var wg sync.WaitGroup
+	for _, profile := range v.profiles {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			profile.Start(v.cfg.Capture.SecondsToRecord)
+		}()
+	}
+	wg.Wait()
+}
+
+// Replays receives every shot as soon as its clips are in memory, before they are written to disk.
+func (v *VideoProfiles) Replays() <-chan Replay {
+	return v.replays
+}
+
+// Save saves the clips of all cameras into a new shot directory along with its shot.json sidecar.
+func (v *VideoProfiles) Save(detection Detection) (*Shot, error) {
+	shot, err := NewShot(v.cfg.VideosDir, detection, v.cfg)
+	if err != nil {
+		return nil, fmt.Errorf("error creating shot: %w", err)
+	}
+	defer close(shot.saving)
+	shot.Number = v.nextNumber()
+
+	saved := make([]savedClip, len(v.profiles))
 	var wg sync.WaitGroup
-	wg.Add(2)
-	go func() {
-		defer wg.Done()
-		v.front.Start(DefaultSecondsToRecord, frontWindow)
-	}()
-	go func() {
-		defer wg.Done()
-		go v.back.Start(DefaultSecondsToRecord, backWindow)
-	}()
+	for i, profile := range v.profiles {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var err error
+			if saved[i], err = profile.Save(detection, shot); err != nil {
+				fmt.Printf("error saving %s video: %v\n", profile.name, err)
+			}
+		}()
+	}
 	wg.Wait()
+
+	// replay while the clips are encoded
+	replay := Replay{Shot: shot, Clips: make([]*Clip, len(saved))}
+	for i, s := range saved {
+		replay.Clips[i] = s.clip
+	}
+	v.replays <- replay
+
+	for i, s := range saved {
+		if s.encoded == nil {
+			continue
+		}
+		if err := <-s.encoded; err != nil {
+			fmt.Printf("error saving %s video: %v\n", v.profiles[i].name, err)
+			continue
+		}
+		shot.AddClip(s.info)
+	}
+
+	// a directory without a shot.json isn't in the library, so retention could never remove it
+	if len(shot.Clips) == 0 {
+		removeShotDir(shot)
+		return nil, fmt.Errorf("no camera saved a clip of shot %s", shot.ID)
+	}
+	if err := shot.Save(); err != nil {
+		removeShotDir(shot)
+		return nil, fmt.Errorf("error saving shot %s: %w", shot.ID, err)
+	}
+	fmt.Printf(">>>>>>>> shot saved to %s\n", shot.Dir())
+	return shot, nil
+}
+
+// removeShotDir removes the directory of a shot that failed to save.
+func removeShotDir(shot *Shot) {
+	if err := os.RemoveAll(shot.Dir()); err != nil {
+		fmt.Printf("error removing shot directory %s: %v\n", shot.Dir(), err)
+	}
+}
+
+// Cameras returns the names of the cameras, in camera index order.
+func (v *VideoProfiles) Cameras() []string {
+	var names []string
+	for _, profile := range v.profiles {
+		names = append(names, profile.name)
+	}
+	return names
+}
+
+// LatestFrame copies a recent frame of the camera into dst, it returns false if the camera isn't live.
+func (v *VideoProfiles) LatestFrame(camera int, dst *gocv.Mat) bool {
+	if camera < 0 || camera >= len(v.profiles) {
+		return false
+	}
+	return v.profiles[camera].LatestFrame(dst)
+}
+
+// Stats returns the capture health metrics of each camera.
+func (v *VideoProfiles) Stats() []CaptureStats {
+	var stats []CaptureStats
+	for _, profile := range v.profiles {
+		stats = append(stats, profile.Stats())
+	}
+	return stats
 }
 
-func (v *VideoProfiles) Save(detection Detection) {
-	go v.front.Save(detection)
-	go v.back.Save(detection)
+// States returns the state of each camera by name.
+func (v *VideoProfiles) States() map[string]CameraState {
+	states := make(map[string]CameraState)
+	for _, profile := range v.profiles {
+		states[profile.name] = profile.State()
+	}
+	return states
 }
 
 func (v *VideoProfiles) Stop() {
-	v.front.Stop()
-	v.back.Stop()
+	for _, profile := range v.profiles {
+		profile.Stop()
+	}
 }
 
 type VideoProfile struct {
-	name                        string
-	cam                         *gocv.VideoCapture
+	name       string
+	cam        *gocv.VideoCapture
+	camCfg     CameraConfig
+	captureCfg CaptureConfig
+	// corrects the lens distortion of captured frames, nil keeps them as captured
+	undistort *Undistorter
+	// the latest frame was undistorted, the buffer is reset on reconnect so its frames all have the same size
+	undistorted bool
+	// camera controls as accepted by the driver
+	controls                    []CameraControl
+	state                       atomic.Value
+	metrics                     captureMetrics
+	fps                         float64
 	durationToCaptureAfterEvent time.Duration
 
+	// copy of a recent frame for live views, refreshed at most every DefaultLiveFrameInterval
+	latestMu sync.Mutex
+	latest   gocv.Mat
+
 	stop chan struct{}
-	save chan struct{}
+	save chan saveRequest
 }
 
-func NewVideoProfile(name string, device int, durationToCaptureAfterEvent time.Duration) (*VideoProfile, error) {
-	cam, err := gocv.VideoCaptureDevice(device)
-	if err != nil {
-		return nil, fmt.Errorf("error opening front camera (0): %w", err)
-	}
+type saveRequest struct {
+	detection Detection
+	shot      *Shot
+	result    chan<- saveResult
+}
 
-	cam.Set(gocv.VideoCaptureFrameWidth, float64(DefaultCamWidth))
-	cam.Set(gocv.VideoCaptureFrameHeight, float64(DefaultCamHeight))
-	cam.Set(gocv.VideoCaptureFPS, DefaultFPS)
+type saveResult struct {
+	saved savedClip
+	err   error
+}
 
-	// Retrieve camera properties
-	width := cam.Get(gocv.VideoCaptureFrameWidth)
-	height := cam.Get(gocv.VideoCaptureFrameHeight)
-	fps := cam.Get(gocv.VideoCaptureFPS)
+// savedClip is a clip taken from the frame buffer, which is encoded to the shot directory in the background.
+type savedClip struct {
+	// in-memory clip, held for the receiver
+	clip *Clip
+	info ShotClip
+	// receives once the clip has been written
+	encoded <-chan error
+}
 
-	// Print camera details
-	fmt.Println("==================================================")
-	fmt.Printf("Camera Details %s (device=%d):\n", name, device)
-	fmt.Printf("Resolution: %.0fx%.0f\n", width, height)
-	fmt.Printf("FPS: %.2f\n", fps)
-	fmt.Println("==================================================")
+func NewVideoProfile(camCfg CameraConfig, cfg Config) (*VideoProfile, error) {
+	cam, controls, err := openCamera(camCfg, cfg.Capture)
+	if err != nil {
+		return nil, err
+	}
 
-	return &VideoProfile{
-		name:                        name,
+	v := &VideoProfile{
+		name:                        camCfg.Name,
 		cam:                         cam,
-		durationToCaptureAfterEvent: durationToCaptureAfterEvent,
-
-		stop: make(chan struct{}),
-		save: make(chan struct{}),
-	}, nil
+		camCfg:                      camCfg,
+		captureCfg:                  cfg.Capture,
+		controls:                    controls,
+		fps:                         cfg.Capture.FPS,
+		durationToCaptureAfterEvent: time.Duration(cfg.Capture.DurationAfterEvent),
+
+		stop:   make(chan struct{}),
+		save:   make(chan saveRequest),
+		latest: gocv.NewMat(),
+	}
+	if cfg.Calibration.Undistort == UndistortCapture {
+		if v.undistort, err = LoadUndistorter(cfg.Calibration.Dir, camCfg.Name); err != nil {
+			fmt.Printf("Error loading lens calibration, %s is captured uncorrected: %v\n", camCfg.Name, err)
+		}
+		// until a frame of another size than calibrated says otherwise
+		v.undistorted = v.undistort != nil
+	}
+	v.state.Store(CameraLive)
+	return v, nil
 }
 
-func (v *VideoProfile) Start(secondsToRecord int, window *VideoPlaybackWindow) (err error) {
+func (v *VideoProfile) Start(secondsToRecord int) (err error) {
 	fmt.Printf(">>>>>>>> starting video capture for %s\n", v.name)
-	frameBuffer := NewVideoFrameBuffer(int(DefaultFPS) * secondsToRecord)
+	frameBuffer := NewVideoFrameBuffer(int(v.fps) * secondsToRecord)
+	v.metrics.buffer(frameBuffer.Len(), frameBuffer.Cap())
 
 	frame := gocv.NewMat()
 	defer frame.Close()
 
-	var playback *VideoPlayback
+	// time of the last frame successfully read from the camera, and of the last live frame
+	lastRead := time.Now()
+	var lastLive time.Time
 
 	var stopped bool
 	for !stopped {
 		select {
 		case <-v.stop:
 			stopped = true
-		case <-v.save:
-			// stop playback if playback is running
-			if playback != nil {
-				playback.Stop()
-			}
+		case req := <-v.save:
 			fmt.Printf("saving video for %s\n", v.name)
 
-			// Format time to a readable format
-			t := time.Now().Format("2006-01-02 15-04-05")
-			file := fmt.Sprintf("videos/%s %s.avi", t, v.name)
-			if err := frameBuffer.Save(
-				file,
-				int(v.cam.Get(gocv.VideoCaptureFrameWidth)),
-				int(v.cam.Get(gocv.VideoCaptureFrameHeight)),
-				float64(v.cam.Get(gocv.VideoCaptureFPS)),
-			); err != nil {
-				fmt.Printf("error saving video: %v\n", err)
-				continue
+			info := ShotClip{
+				Camera: v.name,
+				File:   v.name + ".avi",
+				Width:  int(v.cam.Get(gocv.VideoCaptureFrameWidth)),
+				Height: int(v.cam.Get(gocv.VideoCaptureFrameHeight)),
+				FPS:    v.cam.Get(gocv.VideoCaptureFPS),
+				// controls are re-applied on reconnect, so they may have changed
+				Controls:    v.controls,
+				Undistorted: v.undistorted,
 			}
-
-			var err error
-			playback, err = NewVideoPlayback(v.name, file, DefaultFPS)
+			frameBuffer.Describe(&info, req.detection.ImpactTime)
+			frames, err := frameBuffer.Take()
 			if err != nil {
-				fmt.Printf("error creating capture: %v\n", err)
+				req.result <- saveResult{err: fmt.Errorf("error saving video: %w", err)}
 				continue
 			}
-			go playback.Start(DefaultPlaybackSpeed, window)
+			v.metrics.buffer(frameBuffer.Len(), frameBuffer.Cap())
+			clip := newSharedClip(v.name, frames, v.fps, info.ImpactFrame)
+
+			// hand the clip over for replay straight away, it is encoded in the background
+			encoded := make(chan error, 1)
+			req.result <- saveResult{saved: savedClip{clip: clip.Retain(), info: info, encoded: encoded}}
+			go func() {
+				defer clip.Release()
+				encodeStart := time.Now()
+				if err := clip.Save(req.shot.Path(info.File), info.Width, info.Height, info.FPS); err != nil {
+					encoded <- fmt.Errorf("error saving video: %w", err)
+					return
+				}
+				v.metrics.encoded(time.Since(encodeStart))
+				encoded <- nil
+			}()
 
 		default:
-			if ok := v.cam.Read(&frame); !ok {
+			if ok := v.cam.Read(&frame); !ok || frame.Empty() {
+				v.metrics.readFailure()
+				if time.Since(lastRead) < DefaultCameraReadTimeout {
+					time.Sleep(DefaultCameraReadRetryDelay)
+					continue
+				}
+				fmt.Printf(">>>>>>>> no frames from camera %s for %s, reconnecting\n", v.name, DefaultCameraReadTimeout)
+				if stopped = !v.reconnect(); stopped {
+					continue
+				}
+				// drop the frames captured before the disconnect, so saved clips have no gap
+				frameBuffer.Reset()
+				v.metrics.reconnected()
+				// the frame failed to read, start over with the next frame of the reopened camera
+				lastRead = time.Now()
 				continue
 			}
+			lastRead = time.Now()
+			v.metrics.frame(lastRead)
 			cloned := gocv.NewMat()
 			// Rotate the frame by 180 degrees
 			gocv.Rotate(frame, &cloned, gocv.Rotate180Clockwise)
+			if v.undistort != nil {
+				undistorted := gocv.NewMat()
+				ok := v.undistort.Undistort(cloned, &undistorted)
+				if !ok && ok != v.undistorted {
+					fmt.Printf("frames of %s are %dx%d, not the calibrated size, they are saved uncorrected\n", v.name, cloned.Cols(), cloned.Rows())
+				}
+				v.undistorted = ok
+				cloned.Close()
+				cloned = undistorted
+			}
+
+			if lastRead.Sub(lastLive) >= DefaultLiveFrameInterval {
+				v.latestMu.Lock()
+				cloned.CopyTo(&v.latest)
+				v.latestMu.Unlock()
+				lastLive = lastRead
+			}
 
-			frameBuffer.Append(cloned)
+			frameBuffer.Append(cloned, lastRead)
+			v.metrics.buffer(frameBuffer.Len(), frameBuffer.Cap())
 		}
 	}
 	fmt.Printf(">>>>>>>> video profile capturing stopped for camera %s\n", v.name)
+	if v.cam != nil {
+		v.cam.Close()
+	}
+	if v.undistort != nil {
+		v.undistort.Close()
+	}
 	return nil
 }
 
+// LatestFrame copies a recent frame into dst, it returns false if the camera isn't live.
+func (v *VideoProfile) LatestFrame(dst *gocv.Mat) bool {
+	if v.State() != CameraLive {
+		return false
+	}
+	v.latestMu.Lock()
+	defer v.latestMu.Unlock()
+	if v.latest.Empty() {
+		return false
+	}
+	v.latest.CopyTo(dst)
+	return true
+}
+
+// Stats returns the capture health metrics of the camera.
+func (v *VideoProfile) Stats() CaptureStats {
+	s := v.metrics.snapshot()
+	s.Camera = v.name
+	s.State = v.State()
+	s.TargetFPS = v.fps
+	return s
+}
+
+// State returns whether the camera is live, reconnecting or failed.
+func (v *VideoProfile) State() CameraState {
+	return v.state.Load().(CameraState)
+}
+
+func (v *VideoProfile) setState(state CameraState) {
+	if old := v.state.Swap(state); old != state {
+		fmt.Printf(">>>>>>>> camera %s is %s\n", v.name, state)
+	}
+}
+
+// reconnect closes and reopens the camera with backoff until a frame can be read again.
+// While reconnecting, saves fail straight away so the other cameras can still save their clips.
+// It returns false if the profile was stopped before the camera came back.
+func (v *VideoProfile) reconnect() bool {
+	v.setState(CameraReconnecting)
+	v.cam.Close()
+
+	frame := gocv.NewMat()
+	defer frame.Close()
+
+	backoff := DefaultCameraReconnectBackoff
+	for attempt := 1; ; attempt++ {
+		cam, controls, err := openCamera(v.camCfg, v.captureCfg)
+		if err == nil && cam.Read(&frame) && !frame.Empty() {
+			v.cam = cam
+			v.controls = controls
+			v.setState(CameraLive)
+			return true
+		}
+		if err == nil {
+			err = fmt.Errorf("no frame read")
+			cam.Close()
+		}
+		if attempt == DefaultCameraMaxReconnectAttempts {
+			v.setState(CameraFailed)
+		}
+		fmt.Printf("reconnecting camera %s failed (attempt %d), retrying in %s: %v\n", v.name, attempt, backoff, err)
+
+		retry := time.After(backoff)
+		for waiting := true; waiting; {
+			select {
+			case <-v.stop:
+				v.cam = nil
+				return false
+			case req := <-v.save:
+				req.result <- saveResult{err: fmt.Errorf("camera %s is %s", v.name, v.State())}
+			case <-retry:
+				waiting = false
+			}
+		}
+		backoff = min(backoff*2, DefaultCameraMaxReconnectBackoff)
+	}
+}
+
 func (v *VideoProfile) Stop() {
 	v.stop <- struct{}{}
 }
 
-func (v *VideoProfile) Save(detection Detection) {
+// Save waits until enough video after the detection has been captured, then saves the clip into the shot directory.
+func (v *VideoProfile) Save(detection Detection, shot *Shot) (savedClip, error) {
 	elapsed := time.Since(detection.DetectionTime)
 	delay := v.durationToCaptureAfterEvent - elapsed
 	fmt.Printf("delaying saving video by %s\n", delay)
 	time.Sleep(delay)
-	v.save <- struct{}{}
+
+	result := make(chan saveResult, 1)
+	v.save <- saveRequest{detection: detection, shot: shot, result: result}
+	res := <-result
+	return res.saved, res.err
 }
 
 type VideoFrameBuffer struct {
 	sync.RWMutex
 
-	frames []gocv.Mat
-	idx    int
+	frames []*sharedFrame
+	// time each frame was read from the camera
+	times []time.Time
+	idx   int
 }
 
 // 120 FPS -> to keep 3 seconds before and after impact -> 720 frames
 func NewVideoFrameBuffer(maxFrames int) *VideoFrameBuffer {
 	return &VideoFrameBuffer{
-		frames: make([]gocv.Mat, maxFrames),
+		frames: make([]*sharedFrame, maxFrames),
+		times:  make([]time.Time, maxFrames),
 	}
 }
 
-func (v *VideoFrameBuffer) Append(frame gocv.Mat) {
+// Append takes ownership of the frame, dropping the oldest frame when the buffer is full.
+func (v *VideoFrameBuffer) Append(mat gocv.Mat, t time.Time) {
 	v.Lock()
 	defer v.Unlock()
 
+	frame := newSharedFrame(mat)
 	if v.idx < len(v.frames) {
 		v.frames[v.idx] = frame
+		v.times[v.idx] = t
 		v.idx++
 	} else {
-		v.frames[0].Close()
+		v.frames[0].release()
 		v.frames = append(v.frames[1:], frame)
+		v.times = append(v.times[1:], t)
 	}
 }
-func (v *VideoFrameBuffer) Save(file string, width, height int, fps float64) (err error) {
-	v.RLock()
-	defer v.RUnlock()
 
-	if !v.Full() {
-		return fmt.Errorf("video frame buffer is not full (%d/%d)", v.idx, len(v.frames))
-	}
-	fmt.Printf("--------------------------------------------------\n")
-	fmt.Printf("video frame buffer is full (%d)\nparameters:\n\twidth: %d\n\theight: %d\n\tfps: %f\n",
-		len(v.frames), width, height, fps)
-	fmt.Printf("--------------------------------------------------\n")
+// Reset drops all buffered frames.
+func (v *VideoFrameBuffer) Reset() {
+	v.Lock()
+	defer v.Unlock()
 
-	videoWriter, err := gocv.VideoWriterFile(file, "MJPG", fps, width, height, true)
-	if err != nil {
-		return fmt.Errorf("error creating video writer: %w", err)
+	for i := 0; i < v.idx; i++ {
+		v.frames[i].release()
 	}
-	defer videoWriter.Close()
-
-	for idx, frame := range v.frames {
-		err = videoWriter.Write(frame)
-		if err != nil {
-			return fmt.Errorf("error writing frame (%d): %w", idx, err)
-		}
-	}
-
-	return nil
-}
-func (v *VideoFrameBuffer) Full() bool {
-	return v.idx == len(v.frames)
+	v.idx = 0
 }
 
-type VideoPlayback struct {
-	camName string
-	file    string
-	fps     float64
-
-	stop chan struct{}
-}
+// Describe fills in the frame timing of the buffered clip, relative to the impact time.
+func (v *VideoFrameBuffer) Describe(clip *ShotClip, impactTime time.Time) {
+	v.RLock()
+	defer v.RUnlock()
 
-func NewVideoPlayback(camName string, file string, fps float64) (*VideoPlayback, error) {
-	v := &VideoPlayback{
-		camName: camName,
-		file:    file,
-		fps:     fps,
-		stop:    make(chan struct{}),
+	times := v.times[:v.idx]
+	clip.Frames = len(times)
+	if len(times) < 2 {
+		return
 	}
-	return v, nil
-}
-
-// playbackSpeed is an integer > 0, 0.5 is half speed, 1 is normal speed, 2 is double speed
-// window has to be passed in as must run on the main thread.
-func (v *VideoPlayback) Start(playbackSpeed float64, window *VideoPlaybackWindow) {
-	// Create a Mat to hold the video frames
-	f := gocv.NewMat()
-	defer f.Close()
-
-	// compute time to delay between frames
-	frameDelay := float64(time.Second) / v.fps / playbackSpeed
-
-	for {
-		// Open the video file
-		video, err := gocv.VideoCaptureFile(v.file)
-		if err != nil {
-			fmt.Printf("Error opening video file %s: %v\n", v.file, err)
-			return
+	first, last := times[0], times[len(times)-1]
+	clip.MeasuredFPS = float64(len(times)-1) / last.Sub(first).Seconds()
+	clip.PreRoll = Duration(impactTime.Sub(first))
+	clip.PostRoll = Duration(last.Sub(impactTime))
+
+	// find the frame read closest to the impact
+	for i, t := range times {
+		if t.Sub(impactTime).Abs() < times[clip.ImpactFrame].Sub(impactTime).Abs() {
+			clip.ImpactFrame = i
 		}
-		for {
-			// Read a frame from the video
-			if ok := video.Read(&f); !ok {
-				fmt.Printf(">>>>>>>> Restarting %s video playback\n", v.camName)
-				break
-			}
-			if f.Empty() {
-				continue
-			}
-
-			select {
-			case <-v.stop:
-				fmt.Printf(">>>>>>>> %s video playback stopped\n", v.camName)
-				return
-			default:
-			}
-
-			// Display the frame in the window
-
-			window.Input() <- f
-
-			time.Sleep(time.Duration(frameDelay))
-		}
-		video.Close()
 	}
 }
-func (v *VideoPlayback) Stop() {
-	fmt.Printf("stopping %s video playback\n", v.camName)
-	v.stop <- struct{}{}
-}
 
-type VideoPlaybackWindow struct {
-	*gocv.Window
-	frames chan gocv.Mat
-}
+// Take returns a reference to each buffered frame, the buffer keeps rolling so the next shot can be saved straight away.
+// The frames are shared rather than copied, the caller releases them.
+func (v *VideoFrameBuffer) Take() ([]*sharedFrame, error) {
+	v.Lock()
+	defer v.Unlock()
 
-func NewVideoPlaybackWindow(name string) *VideoPlaybackWindow {
-	return &VideoPlaybackWindow{
-		Window: gocv.NewWindow(name),
-		frames: make(chan gocv.Mat),
+	if !v.Full() {
+		return nil, fmt.Errorf("video frame buffer is not full (%d/%d)", v.idx, len(v.frames))
 	}
-}
-func (v *VideoPlaybackWindow) PlayNextFrame() {
-	select {
-	case frame := <-v.frames:
-		v.Window.IMShow(frame)
-		if key := gocv.WaitKey(1); key == 'q' {
-			break
-		}
-	default:
+	fmt.Printf("--------------------------------------------------\n")
+	fmt.Printf("video frame buffer is full (%d)\n", len(v.frames))
+	fmt.Printf("--------------------------------------------------\n")
+
+	frames := make([]*sharedFrame, len(v.frames))
+	for i, frame := range v.frames {
+		frames[i] = frame.retain()
 	}
+	return frames, nil
 }
-func (v *VideoPlaybackWindow) Input() chan<- gocv.Mat {
-	return v.frames
+func (v *VideoFrameBuffer) Len() int {
+	v.RLock()
+	defer v.RUnlock()
+	return v.idx
+}
+func (v *VideoFrameBuffer) Cap() int {
+	return len(v.frames)
+}
+func (v *VideoFrameBuffer) Full() bool {
+	return v.idx == len(v.frames)
 }
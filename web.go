@@ -0,0 +1,354 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"image"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"gocv.io/x/gocv"
+)
+
+const (
+	// cached thumbnail of the impact frame, written into the shot directory
+	ThumbnailFile  = "thumbnail.jpg"
+	ThumbnailWidth = 320
+	// live streams are sent at a lower rate than they're captured to save bandwidth
+	DefaultWebLiveFrameInterval = time.Second / 15
+)
+
+var webTemplates = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Swing Playback</title>
+<style>
+	body { font-family: sans-serif; background: #111; color: #eee; margin: 1em; }
+	a { color: #8cf; }
+	img { max-width: 100%; background: #000; }
+	.cameras { display: flex; flex-wrap: wrap; gap: 1em; }
+	.camera { flex: 1 1 400px; }
+	table { border-collapse: collapse; width: 100%; }
+	td { padding: 0.4em; border-bottom: 1px solid #333; vertical-align: middle; }
+</style>
+</head>
+<body>
+<h1>Live</h1>
+<div class="cameras">
+{{range .Cameras}}<div class="camera"><h3>{{.}}</h3><img src="/live/{{.}}"></div>
+{{end}}
+</div>
+<h1>Shots</h1>
+<table>
+{{range $shot := .Shots}}<tr>
+	<td><a href="/shots/{{.ID}}"><img src="/shots/{{.ID}}/thumbnail.jpg" width="160"></a></td>
+	<td><a href="/shots/{{.ID}}">{{.ImpactTime.Format "Jan 02 15:04:05"}}</a>{{if .Favorite}} &#9733;{{end}}</td>
+	<td>{{.Trigger}}</td>
+	<td>{{.Club}}</td>
+	<td>{{with .Tempo}}tempo {{printf "%.1f" .Ratio}}:1{{end}}</td>
+	<td>{{range .Tags}}{{.}} {{end}}</td>
+	<td>{{range .Clips}}<a href="/shots/{{$shot.ID}}/files/{{.File}}" download>{{.File}}</a> {{end}}</td>
+</tr>
+{{else}}<tr><td>No shots saved yet</td></tr>
+{{end}}
+</table>
+</body>
+</html>
+`))
+
+var webShotTemplate = template.Must(template.New("shot").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Shot {{.Shot.ID}}</title>
+<style>
+	body { font-family: sans-serif; background: #111; color: #eee; margin: 1em; }
+	a { color: #8cf; margin-right: 0.8em; }
+	img { max-width: 100%; background: #000; }
+	.cameras { display: flex; flex-wrap: wrap; gap: 1em; }
+	.camera { flex: 1 1 400px; }
+</style>
+</head>
+<body>
+<p><a href="/">&larr; all shots</a></p>
+<h1>{{.Shot.ImpactTime.Format "Jan 02 15:04:05"}}</h1>
+<p>{{.Shot.Trigger}} {{.Shot.Club}} {{range .Shot.Tags}}{{.}} {{end}}</p>
+{{with .Shot.Tempo}}<p>Tempo {{printf "%.1f" .Ratio}}:1, backswing {{.Backswing}}, downswing {{.Downswing}}</p>{{end}}
+<p>Speed: {{range .Speeds}}<a href="?speed={{.}}">{{if eq . $.Speed}}<b>{{.}}x</b>{{else}}{{.}}x{{end}}</a>{{end}}</p>
+<div class="cameras">
+{{range .Shot.Clips}}<div class="camera">
+	<h3>{{.Camera}}</h3>
+	<img src="/shots/{{$.Shot.ID}}/play/{{.Camera}}?speed={{$.Speed}}">
+	<p><a href="/shots/{{$.Shot.ID}}/files/{{.File}}" download>download {{.File}}</a></p>
+</div>
+{{end}}
+</div>
+<p><a href="/shots/{{.Shot.ID}}/files/shot.json">shot.json</a></p>
+</body>
+</html>
+`))
+
+// WebServer serves live views, the shot list and replays of saved shots to browsers.
+// It keeps running while capture restarts, live views show the cameras of the latest start.
+type WebServer struct {
+	addr     string
+	library  *Library
+	video    atomic.Pointer[VideoProfiles]
+	cameras  []string
+	defSpeed float64
+}
+
+func NewWebServer(cfg Config, library *Library) *WebServer {
+	var cameras []string
+	for _, camera := range cfg.Cameras {
+		cameras = append(cameras, camera.Name)
+	}
+	return &WebServer{
+		addr:     cfg.Web.Addr,
+		library:  library,
+		cameras:  cameras,
+		defSpeed: cfg.Playback.Speed,
+	}
+}
+
+// SetVideo sets the cameras the live views show, nil while capture is stopped.
+func (s *WebServer) SetVideo(video *VideoProfiles) {
+	s.video.Store(video)
+}
+
+func (s *WebServer) Start() error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", s.index)
+	mux.HandleFunc("GET /api/shots", s.apiShots)
+	mux.HandleFunc("GET /live/{camera}", s.live)
+	mux.HandleFunc("GET /shots/{id}", s.shot)
+	mux.HandleFunc("GET /shots/{id}/thumbnail.jpg", s.thumbnail)
+	mux.HandleFunc("GET /shots/{id}/play/{camera}", s.play)
+	mux.HandleFunc("GET /shots/{id}/files/{file}", s.file)
+
+	fmt.Printf(">>>>>>>> web ui listening on http://%s\n", s.addr)
+	if err := http.ListenAndServe(s.addr, mux); err != nil {
+		return fmt.Errorf("error serving web ui: %w", err)
+	}
+	return nil
+}
+
+func (s *WebServer) index(w http.ResponseWriter, r *http.Request) {
+	shots, err := s.library.Shots()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	slices.Reverse(shots)
+	data := struct {
+		Cameras []string
+		Shots   []*Shot
+	}{s.cameras, shots}
+	if err := webTemplates.Execute(w, data); err != nil {
+		fmt.Printf("error rendering web ui: %v\n", err)
+	}
+}
+
+func (s *WebServer) apiShots(w http.ResponseWriter, r *http.Request) {
+	shots, err := s.library.Shots()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	slices.Reverse(shots)
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(shots)
+}
+
+func (s *WebServer) shot(w http.ResponseWriter, r *http.Request) {
+	shot, err := s.library.Shot(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	data := struct {
+		Shot   *Shot
+		Speeds []float64
+		Speed  float64
+	}{shot, PlaybackSpeeds, s.speed(r)}
+	if err := webShotTemplate.Execute(w, data); err != nil {
+		fmt.Printf("error rendering web ui: %v\n", err)
+	}
+}
+
+// live streams a camera's live view as MJPEG.
+func (s *WebServer) live(w http.ResponseWriter, r *http.Request) {
+	camera := slices.Index(s.cameras, r.PathValue("camera"))
+	if camera < 0 {
+		http.Error(w, "unknown camera", http.StatusNotFound)
+		return
+	}
+	writeMJPEG(w, r, DefaultWebLiveFrameInterval, func(frame *gocv.Mat) bool {
+		// keep the stream open while the camera reconnects or capture restarts
+		if video := s.video.Load(); video != nil {
+			video.LatestFrame(camera, frame)
+		}
+		return true
+	})
+}
+
+// play streams a shot's clip as MJPEG at the requested speed, looping until the browser disconnects.
+// Frames are decoded as they are sent, so viewers don't hold whole clips in memory.
+func (s *WebServer) play(w http.ResponseWriter, r *http.Request) {
+	shot, err := s.library.Shot(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	info, ok := shot.Clip(r.PathValue("camera"))
+	if !ok {
+		http.Error(w, "unknown camera", http.StatusNotFound)
+		return
+	}
+	video, err := gocv.VideoCaptureFile(shot.Path(info.File))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	defer video.Close()
+
+	interval := time.Duration(float64(time.Second) / info.PlaybackFPS() / s.speed(r))
+	writeMJPEG(w, r, interval, func(frame *gocv.Mat) bool {
+		if video.Read(frame) && !frame.Empty() {
+			return true
+		}
+		// loop from the start
+		video.Set(gocv.VideoCapturePosFrames, 0)
+		return video.Read(frame) && !frame.Empty()
+	})
+}
+
+func (s *WebServer) thumbnail(w http.ResponseWriter, r *http.Request) {
+	shot, err := s.library.Shot(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	file := shot.Path(ThumbnailFile)
+	if _, err := os.Stat(file); err != nil {
+		if err := writeThumbnail(shot, file); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	http.ServeFile(w, r, file)
+}
+
+// file downloads a file of the shot.
+func (s *WebServer) file(w http.ResponseWriter, r *http.Request) {
+	shot, err := s.library.Shot(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	name := r.PathValue("file")
+	if filepath.Base(name) != name {
+		http.Error(w, "invalid file", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", shot.ID+" "+name))
+	http.ServeFile(w, r, shot.Path(name))
+}
+
+// speed returns the requested replay speed, limited to the range of the speed presets.
+func (s *WebServer) speed(r *http.Request) float64 {
+	speed, err := strconv.ParseFloat(r.URL.Query().Get("speed"), 64)
+	if err != nil || math.IsNaN(speed) {
+		speed = s.defSpeed
+	}
+	return min(max(speed, slices.Min(PlaybackSpeeds)), slices.Max(PlaybackSpeeds))
+}
+
+// writeThumbnail saves the impact frame of the shot's first clip as a small jpeg.
+func writeThumbnail(shot *Shot, file string) error {
+	if len(shot.Clips) == 0 {
+		return fmt.Errorf("shot %s has no clips", shot.ID)
+	}
+	info := shot.Clips[0]
+	video, err := gocv.VideoCaptureFile(shot.Path(info.File))
+	if err != nil {
+		return fmt.Errorf("error opening video file: %w", err)
+	}
+	defer video.Close()
+
+	frame := gocv.NewMat()
+	defer frame.Close()
+	video.Set(gocv.VideoCapturePosFrames, float64(info.ImpactFrame))
+	if ok := video.Read(&frame); !ok || frame.Empty() {
+		return fmt.Errorf("error reading impact frame of shot %s", shot.ID)
+	}
+
+	small := gocv.NewMat()
+	defer small.Close()
+	height := frame.Rows() * ThumbnailWidth / frame.Cols()
+	gocv.Resize(frame, &small, image.Pt(ThumbnailWidth, height), 0, 0, gocv.InterpolationArea)
+
+	buf, err := gocv.IMEncode(gocv.JPEGFileExt, small)
+	if err != nil {
+		return fmt.Errorf("error encoding thumbnail: %w", err)
+	}
+	defer buf.Close()
+	if err := writeFileAtomic(file, buf.GetBytes()); err != nil {
+		return fmt.Errorf("error writing thumbnail: %w", err)
+	}
+	return nil
+}
+
+// writeMJPEG streams frames as a multipart MJPEG response until next returns false or the client disconnects.
+func writeMJPEG(w http.ResponseWriter, r *http.Request, interval time.Duration, next func(frame *gocv.Mat) bool) {
+	const boundary = "frame"
+	w.Header().Set("Content-Type", "multipart/x-mixed-replace; boundary="+boundary)
+	w.Header().Set("Cache-Control", "no-cache")
+	flusher, _ := w.(http.Flusher)
+
+	frame := gocv.NewMat()
+	defer frame.Close()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if !next(&frame) {
+			return
+		}
+		if !frame.Empty() {
+			buf, err := gocv.IMEncode(gocv.JPEGFileExt, frame)
+			if err != nil {
+				fmt.Printf("error encoding frame: %v\n", err)
+				return
+			}
+			_, err = fmt.Fprintf(w, "--%s\r\nContent-Type: image/jpeg\r\nContent-Length: %d\r\n\r\n", boundary, buf.Len())
+			if err == nil {
+				_, err = w.Write(buf.GetBytes())
+			}
+			if err == nil {
+				_, err = w.Write([]byte("\r\n"))
+			}
+			buf.Close()
+			if err != nil {
+				return
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"image"
+	"sync"
+	"sync/atomic"
+
+	"gocv.io/x/gocv"
+)
+
+// Overlay draws on top of the frames shown in a window, it is called on the main thread.
+type Overlay interface {
+	Draw(img *gocv.Mat, frame PlaybackFrame)
+}
+
+// VideoPlaybackWindow shows a camera's replays, either in its own window or as a panel of a GridWindow.
+type VideoPlaybackWindow struct {
+	// nil for a panel, the grid shows its display instead
+	*gocv.Window
+	fullscreen bool
+	// mouse handler of a panel, called by the grid
+	mouse     gocv.MouseHandlerFunc
+	mouseData any
+
+	// camera index of the clips shown in the window
+	camera   int
+	controls *PlaybackController
+	overlays []Overlay
+	// corrects the lens distortion of frames saved without correction, nil shows frames unchanged
+	undistort *Undistorter
+	// the display is undistorted, mouse events are mapped back to the camera's picture
+	remapped    bool
+	undistorted gocv.Mat
+
+	// the window keeps its own copy of the latest frame, so clips can be released at any time
+	mu      sync.Mutex
+	next    PlaybackFrame
+	hasNext bool
+	// the latest frame and the same frame with the overlays drawn on top
+	shown   PlaybackFrame
+	display gocv.Mat
+	refresh atomic.Bool
+
+	// scrubbing trackbar, created once the first frame is shown
+	trackbar    *gocv.Trackbar
+	trackbarPos int
+}
+
+func NewVideoPlaybackWindow(name string, camera int, controls *PlaybackController) *VideoPlaybackWindow {
+	v := NewVideoPlaybackPanel(camera, controls)
+	v.Window = gocv.NewWindow(name)
+	return v
+}
+
+// NewVideoPlaybackPanel creates a camera's view without a window of its own, to be shown by a GridWindow.
+func NewVideoPlaybackPanel(camera int, controls *PlaybackController) *VideoPlaybackWindow {
+	return &VideoPlaybackWindow{
+		camera:      camera,
+		controls:    controls,
+		next:        PlaybackFrame{Frame: gocv.NewMat()},
+		shown:       PlaybackFrame{Frame: gocv.NewMat()},
+		display:     gocv.NewMat(),
+		undistorted: gocv.NewMat(),
+	}
+}
+
+// AddOverlay adds an overlay drawn on every frame, in the order added.
+func (v *VideoPlaybackWindow) AddOverlay(o Overlay) {
+	v.overlays = append(v.overlays, o)
+}
+
+// SetUndistorter corrects the lens distortion of the frames shown in the window.
+func (v *VideoPlaybackWindow) SetUndistorter(u *Undistorter) {
+	v.undistort = u
+}
+
+// Refresh redraws the current frame on the next PlayNextFrame, e.g. after an overlay changed while paused.
+func (v *VideoPlaybackWindow) Refresh() {
+	v.refresh.Store(true)
+}
+
+// SetMouseHandler sets the window's mouse handler, a panel's handler gets the events of its area of the grid.
+func (v *VideoPlaybackWindow) SetMouseHandler(handler gocv.MouseHandlerFunc, userdata any) {
+	// handlers get the coordinates of the frame the overlays are drawn on
+	mapped := func(event, x, y, flags int, userdata any) {
+		if v.remapped {
+			pt := v.undistort.Source(image.Pt(x, y))
+			x, y = pt.X, pt.Y
+		}
+		handler(event, x, y, flags, userdata)
+	}
+	if v.Window != nil {
+		v.Window.SetMouseHandler(mapped, userdata)
+		return
+	}
+	v.mouse, v.mouseData = mapped, userdata
+}
+
+// ApplyLayout places the window, a panel is placed by its grid instead.
+func (v *VideoPlaybackWindow) ApplyLayout(layout WindowLayout) {
+	if v.Window == nil {
+		return
+	}
+	applyLayout(v.Window, layout)
+}
+
+func (v *VideoPlaybackWindow) ToggleFullscreen() {
+	if v.Window == nil {
+		return
+	}
+	v.fullscreen = !v.fullscreen
+	setFullscreen(v.Window, v.fullscreen)
+}
+
+func (v *VideoPlaybackWindow) PlayNextFrame() {
+	if v.render() {
+		v.Window.IMShow(v.display)
+	}
+}
+
+// render draws the latest frame with its overlays into the display, it returns false if nothing changed.
+func (v *VideoPlaybackWindow) render() bool {
+	// seek if the trackbar was dragged since the last frame was shown
+	if v.trackbar != nil {
+		if pos := v.trackbar.GetPos(); pos != v.trackbarPos {
+			v.trackbarPos = pos
+			v.controls.Control(PlaybackControl{Action: PlaybackSeek, Camera: v.camera, Frame: pos})
+		}
+	}
+
+	v.mu.Lock()
+	hasNext := v.hasNext
+	if hasNext {
+		// swap the pending frame in, the old shown mat is reused for the next frame
+		frame := v.next
+		v.next.Frame = v.shown.Frame
+		v.shown = frame
+		v.hasNext = false
+	}
+	v.mu.Unlock()
+
+	if !hasNext && !v.refresh.Swap(false) {
+		return false
+	}
+	if v.shown.Frame.Empty() {
+		return false
+	}
+	v.shown.Frame.CopyTo(&v.display)
+	for _, o := range v.overlays {
+		o.Draw(&v.display, v.shown)
+	}
+	// overlays are in the camera's picture, so they are undistorted with it and stay aligned
+	v.remapped = false
+	if v.undistort != nil && v.undistort.needed(v.shown) {
+		if v.remapped = v.undistort.Undistort(v.display, &v.undistorted); v.remapped {
+			v.display, v.undistorted = v.undistorted, v.display
+		}
+	}
+	if hasNext {
+		v.updateTrackbar(v.shown)
+	}
+	return true
+}
+
+func (v *VideoPlaybackWindow) updateTrackbar(frame PlaybackFrame) {
+	// panels have no window to put a trackbar on
+	if frame.Count <= 1 || v.Window == nil {
+		return
+	}
+	if v.trackbar == nil {
+		v.trackbar = v.Window.CreateTrackbar("frame", frame.Count-1)
+	}
+	v.trackbar.SetMax(frame.Count - 1)
+	v.trackbar.SetPos(frame.Index)
+	v.trackbarPos = frame.Index
+}
+
+// Show copies the frame to be shown on the next PlayNextFrame, replacing any frame not shown yet.
+func (v *VideoPlaybackWindow) Show(frame PlaybackFrame) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	frame.Frame.CopyTo(&v.next.Frame)
+	frame.Frame = v.next.Frame
+	v.next = frame
+	v.hasNext = true
+}
+
+func (v *VideoPlaybackWindow) Close() error {
+	v.next.Frame.Close()
+	v.shown.Frame.Close()
+	v.display.Close()
+	v.undistorted.Close()
+	if v.Window == nil {
+		return nil
+	}
+	return v.Window.Close()
+}